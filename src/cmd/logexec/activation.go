@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// listenFdsStart is the first file descriptor passed by socket activation.
+const listenFdsStart = 3
+
+var (
+	// listenFds holds the sockets logexec was started with through systemd
+	// socket activation, which are passed on to the main command.
+	listenFds     []*os.File
+	listenFdNames string
+)
+
+// takeListenFds claims the sockets passed with LISTEN_FDS if LISTEN_PID
+// names logexec. The sockets are marked close-on-exec and the variables are
+// removed from the environment, so that only the main command gets them and
+// not the -run commands or hooks.
+func takeListenFds() {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return
+	}
+	for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+		syscall.CloseOnExec(fd)
+		listenFds = append(listenFds, os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd)))
+	}
+	listenFdNames = os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	debugf("Passing %d socket activation fds to the command", n)
+}
+
+// passListenFds hands the activated sockets to cmd at the descriptors they
+// were received on, and reports whether there were any. LISTEN_PID has to
+// hold the pid of the command itself, which is only known once it has been
+// forked, so it is left to the trampoline of the command.
+func passListenFds(cmd *exec.Cmd) bool {
+	if len(listenFds) == 0 {
+		return false
+	}
+	cmd.ExtraFiles = append([]*os.File(nil), listenFds...)
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env, "LISTEN_FDS="+strconv.Itoa(len(listenFds)))
+	if listenFdNames != "" {
+		env = append(env, "LISTEN_FDNAMES="+listenFdNames)
+	}
+	cmd.Env = env
+	return true
+}
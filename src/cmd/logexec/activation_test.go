@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+// runTestActivation takes the sockets passed to it as logexec does and runs
+// a main command, which is passed them, and then another command, which is
+// not. A LISTEN_PID of "self" is replaced by the pid of the test binary, as
+// systemd sets it once it has forked.
+func runTestActivation() int {
+	if os.Getenv("LISTEN_PID") == "self" {
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	}
+	takeListenFds()
+	cmd := exec.Command("sh", "-c",
+		`[ "$LISTEN_PID" = $$ ] && pid=self; echo "$pid $LISTEN_FDS $LISTEN_FDNAMES"; echo main >&3`)
+	cmd.Stdout = os.Stdout
+	if err := startTrampoline(cmd, trampoline{ListenPID: passListenFds(cmd)}); err != nil {
+		return 2
+	}
+	cmd.Wait()
+	other := exec.Command("sh", "-c", `echo "$LISTEN_FDS $LISTEN_FDNAMES"; echo other >&3`)
+	other.Stdout = os.Stdout
+	other.Run()
+	return 0
+}
+
+func TestSocketActivation(t *testing.T) {
+	tests := []struct {
+		pid, fds, names string
+		out             string
+		// sock is what the commands wrote to the first socket.
+		sock string
+	}{
+		{"self", "1", "web", "self 1 web\n \n", "main\n"},
+		{"self", "2", "web:admin", "self 2 web:admin\n \n", "main\n"},
+		{"1", "1", "web", " 1 web\n1 web\n", "main\nother\n"},
+		{"self", "x", "", " x \nx \n", "main\nother\n"},
+	}
+	for _, tt := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), "LOGEXEC_TEST_ACTIVATION=1",
+			"LISTEN_PID="+tt.pid, "LISTEN_FDS="+tt.fds, "LISTEN_FDNAMES="+tt.names)
+		cmd.ExtraFiles = []*os.File{w, w}
+		out, err := cmd.Output()
+		w.Close()
+		sock, _ := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil || string(out) != tt.out || string(sock) != tt.sock {
+			t.Errorf("Error on %v, got %v, %q, %q", tt, err, out, sock)
+		}
+	}
+}
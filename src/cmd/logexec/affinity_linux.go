@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const maxCPUs = 1024
+
+var errInvalidCPUList = errors.New("invalid CPU list, want e.g. 0-3,6")
+
+// cpuSet is a CPU affinity mask given as a list such as "0-3,6".
+type cpuSet struct {
+	list string
+	mask [maxCPUs / 64]uint64
+}
+
+func (c cpuSet) String() string {
+	return c.list
+}
+
+func (c *cpuSet) Set(to string) error {
+	var mask [maxCPUs / 64]uint64
+	for _, part := range strings.Split(to, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		lo, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return errInvalidCPUList
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			hi, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return errInvalidCPUList
+			}
+		}
+		if lo < 0 || hi < lo || hi >= maxCPUs {
+			return errInvalidCPUList
+		}
+		for cpu := lo; cpu <= hi; cpu++ {
+			mask[cpu/64] |= 1 << uint(cpu%64)
+		}
+	}
+	c.list = to
+	c.mask = mask
+	return nil
+}
+
+var cpuAffinity cpuSet
+
+func init() {
+	flag.Var(&cpuAffinity, "cpus", "CPUs to pin the child to, e.g. 0-3,6")
+}
+
+// applyAffinity pins the calling thread, and so the child forked from it,
+// to the requested CPUs.
+func applyAffinity() error {
+	if cpuAffinity.list == "" {
+		return nil
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0,
+		unsafe.Sizeof(cpuAffinity.mask), uintptr(unsafe.Pointer(&cpuAffinity.mask)))
+	if errno != 0 {
+		return fmt.Errorf("setting CPU affinity: %v", errno)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCPUSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+	}{
+		{"0", 0x1},
+		{"0-3", 0xf},
+		{"1,3", 0xa},
+		{"0-1,4-5", 0x33},
+	}
+	for _, tt := range tests {
+		var c cpuSet
+		if err := c.Set(tt.in); err != nil {
+			t.Errorf("Error on %v: %v", tt.in, err)
+			continue
+		}
+		if c.mask[0] != tt.want {
+			t.Errorf("Error on %v, got %#x", tt.in, c.mask[0])
+		}
+	}
+
+	for _, in := range []string{"", "a", "3-1", "-1", "0-1024"} {
+		var c cpuSet
+		if err := c.Set(in); err == nil {
+			t.Errorf("Expected error on %v", in)
+		}
+	}
+}
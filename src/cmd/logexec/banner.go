@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// secretName matches environment variable names whose values are redacted
+// even when they are allowed by -banner-env.
+var secretName = regexp.MustCompile(`(?i)(key|secret|token|passw|credential|auth|cookie|session)`)
+
+// envPatterns collects comma separated environment variable names, which
+// may contain * wildcards.
+type envPatterns []string
+
+func (p envPatterns) String() string {
+	return strings.Join(p, ",")
+}
+
+func (p *envPatterns) Set(to string) error {
+	*p = nil
+	for _, name := range strings.Split(to, ",") {
+		if _, err := path.Match(name, ""); err != nil || name == "" {
+			return fmt.Errorf("invalid environment variable pattern %q", name)
+		}
+		*p = append(*p, name)
+	}
+	return nil
+}
+
+var (
+	startBanner bool
+	bannerAllow = envPatterns{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_*", "TZ", "PWD"}
+)
+
+func init() {
+	flag.BoolVar(&startBanner, "banner", false,
+		"Log how each command was invoked when it starts: its resolved path, arguments, working directory, user and environment")
+	flag.Var(&bannerAllow, "banner-env",
+		"environment variables whose values -banner logs, e.g. PATH,APP_*; the values of others and of names that look secret are redacted")
+}
+
+// bannerEnv returns env with the values of the variables not allowed, or
+// with secret looking names, replaced by <redacted>, sorted by name.
+func bannerEnv(env []string, allow envPatterns) string {
+	var vars []string
+	for _, kv := range env {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		allowed := false
+		for _, p := range allow {
+			if ok, _ := path.Match(p, name); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed || secretName.MatchString(name) {
+			kv = name + "=<redacted>"
+		}
+		vars = append(vars, strconv.Quote(kv))
+	}
+	sort.Strings(vars)
+	return strings.Join(vars, " ")
+}
+
+// logBanner logs how c was invoked, so that a run can be reconstructed from
+// the log alone.
+func logBanner(c *child) {
+	env := c.cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	dir := c.cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	root := ""
+	if chrootDir != "" {
+		root = fmt.Sprintf(" chroot=%q", chrootDir)
+	}
+	fmt.Fprintf(c.stdout, "Command invocation: pid=%d path=%q args=%s dir=%q%s user=%s uid=%d gid=%d env=[%s]%s",
+		c.cmd.Process.Pid, c.path, quoteArgs(c.cmd.Args), dir, root, name, os.Getuid(), os.Getgid(),
+		bannerEnv(env, bannerAllow), runIDField())
+}
+
+// quoteArgs returns args quoted and separated by spaces, so that arguments
+// with spaces in them can be told apart.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = strconv.Quote(a)
+	}
+	return "[" + strings.Join(quoted, " ") + "]"
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestBannerEnv(t *testing.T) {
+	env := []string{"PATH=/usr/bin", "APP_MODE=prod", "APP_TOKEN=s3cret", "DB_PASSWORD=hunter2", "OTHER=x", "EMPTY="}
+	tests := []struct {
+		allow envPatterns
+		want  string
+	}{
+		{envPatterns{"PATH", "APP_*"}, `"APP_MODE=prod" "APP_TOKEN=<redacted>" "DB_PASSWORD=<redacted>" "EMPTY=<redacted>" "OTHER=<redacted>" "PATH=/usr/bin"`},
+		{envPatterns{"*"}, `"APP_MODE=prod" "APP_TOKEN=<redacted>" "DB_PASSWORD=<redacted>" "EMPTY=" "OTHER=x" "PATH=/usr/bin"`},
+	}
+	for _, tt := range tests {
+		if got := bannerEnv(env, tt.allow); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.allow, got)
+		}
+	}
+}
+
+func TestEnvPatternsSet(t *testing.T) {
+	var p envPatterns
+	if err := p.Set("PATH,APP_*"); err != nil || p.String() != "PATH,APP_*" {
+		t.Errorf("Error on PATH,APP_*, got %v, %v", p, err)
+	}
+	for _, s := range []string{"PATH,", "[", ""} {
+		if err := p.Set(s); err == nil {
+			t.Errorf("Error on %q, got no error", s)
+		}
+	}
+}
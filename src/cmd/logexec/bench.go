@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	benchDuration = 10 * time.Second
+	benchLineSize = 100
+	benchRate     int
+	benchBurst    = 1
+)
+
+func init() {
+	flag.DurationVar(&benchDuration, "bench-duration", benchDuration,
+		"how long the bench subcommand generates lines for")
+	flag.IntVar(&benchLineSize, "bench-line-size", benchLineSize,
+		"length of the lines generated by the bench subcommand, without the newline")
+	flag.IntVar(&benchRate, "bench-rate", 0,
+		"lines per second generated by the bench subcommand, 0 for as fast as they are taken")
+	flag.IntVar(&benchBurst, "bench-burst", benchBurst,
+		"number of lines the bench subcommand writes at once")
+}
+
+// benchLine returns line n of the bench load, numbered so that lost and
+// reordered lines can be spotted, and padded or cut to size.
+func benchLine(n int64, size int) []byte {
+	b := append([]byte("bench line "), strconv.FormatInt(n, 10)...)
+	for i := 0; len(b) < size; i++ {
+		b = append(b, " abcdefghijklmnopqrstuvwxyz"[i%27])
+	}
+	return append(b[:size], '\n')
+}
+
+// generateBench writes bursts of lines to w at the -bench-rate until
+// -bench-duration has passed, and returns the number of lines written.
+// Time spent blocked in w counts against the rate, as a command would see
+// it.
+func generateBench(w io.Writer) int64 {
+	var interval time.Duration
+	if benchRate > 0 {
+		interval = time.Second * time.Duration(benchBurst) / time.Duration(benchRate)
+	}
+	var n int64
+	var burst []byte
+	start := time.Now()
+	next := start
+	for time.Since(start) < benchDuration {
+		burst = burst[:0]
+		for i := 0; i < benchBurst; i++ {
+			burst = append(burst, benchLine(n+int64(i), benchLineSize)...)
+		}
+		if _, err := w.Write(burst); err != nil {
+			break
+		}
+		n += int64(benchBurst)
+		if interval > 0 {
+			next = next.Add(interval)
+			time.Sleep(time.Until(next))
+		}
+	}
+	return n
+}
+
+// runBench logs generated lines through the same path as the stdout of a
+// command, with the options given, and prints the throughput, allocations
+// and drops. It returns the exit status.
+func runBench() int {
+	if benchLineSize < 1 || benchBurst < 1 || benchRate < 0 {
+		fatalf("Bench line size and burst must be at least 1, and rate must not be negative")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		fatalf("Error initializing bench pipe: %v", err)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	generated := make(chan int64, 1)
+	go func() {
+		generated <- generateBench(w)
+		w.Close()
+	}()
+	status, err := runPipe(context.Background(), r, "bench input")
+	if err != nil {
+		fatalf("Error %v", err)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	// The input was logged as the stdout of the run of runPipe.
+	stdout := outputStreams()[0].stats
+	dropped := atomic.LoadInt64(&stdout.dropped)
+	lines := atomic.LoadInt64(&stdout.Lines) - dropped
+	perLine := func(v uint64) float64 {
+		if lines == 0 {
+			return 0
+		}
+		return float64(v) / float64(lines)
+	}
+	fmt.Printf("generated=%d logged=%d dropped=%d truncated=%d duration=%v\n",
+		<-generated, lines, dropped, atomic.LoadInt64(&stdout.Truncated),
+		elapsed.Round(time.Millisecond))
+	fmt.Printf("lines_per_second=%.0f bytes_per_second=%.0f allocs_per_line=%.1f alloc_bytes_per_line=%.0f queue_high_water=%d\n",
+		float64(lines)/elapsed.Seconds(),
+		float64(atomic.LoadInt64(&stdout.Bytes))/elapsed.Seconds(),
+		perLine(after.Mallocs-before.Mallocs), perLine(after.TotalAlloc-before.TotalAlloc),
+		atomic.LoadInt64(&queueHighWater))
+	return status
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBenchLine(t *testing.T) {
+	tests := []struct {
+		n    int64
+		size int
+		want string
+	}{
+		{0, 20, "bench line 0 abcdefg\n"},
+		{123, 16, "bench line 123 a\n"},
+		{123, 5, "bench\n"},
+	}
+	for _, tt := range tests {
+		if got := string(benchLine(tt.n, tt.size)); got != tt.want {
+			t.Errorf("Error on %v, got %q", tt, got)
+		}
+	}
+}
+
+func TestGenerateBench(t *testing.T) {
+	defer func(d time.Duration, size, rate, burst int) {
+		benchDuration, benchLineSize, benchRate, benchBurst = d, size, rate, burst
+	}(benchDuration, benchLineSize, benchRate, benchBurst)
+	benchDuration, benchLineSize, benchRate, benchBurst = 200*time.Millisecond, 10, 100, 5
+
+	var out bytes.Buffer
+	n := generateBench(&out)
+	if n < 10 || n > 25 || n%5 != 0 {
+		t.Errorf("Error on rate 100, got %d lines", n)
+	}
+	if lines := int64(bytes.Count(out.Bytes(), []byte("\n"))); lines != n {
+		t.Errorf("Error on line count, got %d written for %d", lines, n)
+	}
+}
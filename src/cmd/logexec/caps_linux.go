@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prCapbsetDrop     = 24
+	prSetNoNewPrivs   = 38
+	prCapAmbient      = 47
+	prCapAmbientLower = 3
+	linuxCapVersion3  = 0x20080522
+	linuxCapU32s      = 2
+)
+
+var capabilityByName = map[string]int{
+	"chown":              0,
+	"dac_override":       1,
+	"dac_read_search":    2,
+	"fowner":             3,
+	"fsetid":             4,
+	"kill":               5,
+	"setgid":             6,
+	"setuid":             7,
+	"setpcap":            8,
+	"linux_immutable":    9,
+	"net_bind_service":   10,
+	"net_broadcast":      11,
+	"net_admin":          12,
+	"net_raw":            13,
+	"ipc_lock":           14,
+	"ipc_owner":          15,
+	"sys_module":         16,
+	"sys_rawio":          17,
+	"sys_chroot":         18,
+	"sys_ptrace":         19,
+	"sys_pacct":          20,
+	"sys_admin":          21,
+	"sys_boot":           22,
+	"sys_nice":           23,
+	"sys_resource":       24,
+	"sys_time":           25,
+	"sys_tty_config":     26,
+	"mknod":              27,
+	"lease":              28,
+	"audit_write":        29,
+	"audit_control":      30,
+	"setfcap":            31,
+	"mac_override":       32,
+	"mac_admin":          33,
+	"syslog":             34,
+	"wake_alarm":         35,
+	"block_suspend":      36,
+	"audit_read":         37,
+	"perfmon":            38,
+	"bpf":                39,
+	"checkpoint_restore": 40,
+}
+
+// capList is a comma separated list of capability names, with or without
+// the "cap_" prefix, or "all".
+type capList []string
+
+func (c capList) String() string {
+	return strings.Join(c, ",")
+}
+
+func (c *capList) Set(to string) error {
+	var caps []string
+	for _, name := range strings.Split(strings.ToLower(to), ",") {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "cap_")
+		if name == "all" {
+			caps = caps[:0]
+			for n := range capabilityByName {
+				caps = append(caps, n)
+			}
+			sort.Strings(caps)
+			break
+		}
+		if _, ok := capabilityByName[name]; !ok {
+			return fmt.Errorf("unknown capability %q", name)
+		}
+		caps = append(caps, name)
+	}
+	*c = caps
+	return nil
+}
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+var (
+	noNewPrivs bool
+	dropCaps   capList
+)
+
+func init() {
+	flag.BoolVar(&noNewPrivs, "no-new-privs", false,
+		"Prevent the child from gaining privileges through exec")
+	flag.Var(&dropCaps, "drop-caps",
+		"capabilities to drop from the child, e.g. net_raw,sys_admin or all")
+}
+
+func prctl(option, arg2, arg3 uintptr) error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, option, arg2, arg3, 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// applyCapabilities drops the capabilities named in drop from the bounding,
+// ambient and inheritable sets of the calling thread, which is enough to
+// keep them from a command it execs, and sets no_new_privs if asked to.
+// The thread's own effective set is left alone, but it can no longer raise
+// a dropped capability, so this has to come after any setup needing one.
+func applyCapabilities(drop []string, noNewPrivs bool) error {
+	if len(drop) > 0 {
+		hdr := capHeader{version: linuxCapVersion3}
+		var data [linuxCapU32s]capData
+		_, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET,
+			uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+		if errno != 0 {
+			return fmt.Errorf("reading capabilities: %v", errno)
+		}
+
+		for _, name := range drop {
+			c := capabilityByName[name]
+			// Capabilities newer than the running kernel are absent anyway.
+			err := prctl(prCapbsetDrop, uintptr(c), 0)
+			if err != nil && err != syscall.EINVAL {
+				return fmt.Errorf("dropping %v from bounding set: %v", name, err)
+			}
+			err = prctl(prCapAmbient, prCapAmbientLower, uintptr(c))
+			if err != nil && err != syscall.EINVAL {
+				return fmt.Errorf("dropping %v from ambient set: %v", name, err)
+			}
+			data[c/32].inheritable &^= 1 << uint(c%32)
+		}
+
+		_, _, errno = syscall.RawSyscall(syscall.SYS_CAPSET,
+			uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+		if errno != 0 {
+			return fmt.Errorf("setting capabilities: %v", errno)
+		}
+	}
+
+	if noNewPrivs {
+		if err := prctl(prSetNoNewPrivs, 1, 0); err != nil {
+			return fmt.Errorf("setting no_new_privs: %v", err)
+		}
+	}
+	return nil
+}
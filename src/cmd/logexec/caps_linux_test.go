@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCapList(t *testing.T) {
+	var c capList
+	if err := c.Set("CAP_NET_RAW, sys_admin"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if c.String() != "net_raw,sys_admin" {
+		t.Errorf("Error on names, got %v", c)
+	}
+
+	if err := c.Set("all"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(c) != len(capabilityByName) {
+		t.Errorf("Error on all, got %v", c)
+	}
+
+	if err := c.Set("net_raw,bogus"); err == nil {
+		t.Errorf("Expected error on unknown capability")
+	}
+}
+
+// procStatus returns the fields of /proc/self/status as the command run
+// by cmd sees it.
+func procStatus(t *testing.T, cmd *exec.Cmd, tr trampoline) map[string]string {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := startTrampoline(cmd, tr)
+	if err == nil {
+		err = cmd.Wait()
+	}
+	if err != nil {
+		t.Fatalf("Error running %v: %v", cmd.Args, err)
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(out.String(), "\n") {
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			fields[line[:i]] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return fields
+}
+
+func TestTrampolineCapabilities(t *testing.T) {
+	if syscall.Getuid() != 0 {
+		t.Skip("dropping capabilities needs root")
+	}
+	chroot := capabilityByName["sys_chroot"]
+	umask := 022
+	tests := []struct {
+		tr     trampoline
+		chroot string
+	}{
+		{trampoline{DropCaps: []string{"sys_chroot"}}, ""},
+		{trampoline{DropCaps: []string{"sys_chroot"}, Umask: &umask}, "/"},
+		{trampoline{DropCaps: []string{"sys_chroot", "net_raw"}, NoNewPrivs: true}, "/"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("/bin/cat", "/proc/self/status")
+		cmd.SysProcAttr = &syscall.SysProcAttr{Chroot: tt.chroot}
+		status := procStatus(t, cmd, tt.tr)
+		bnd, err := strconv.ParseUint(status["CapBnd"], 16, 64)
+		if err != nil || bnd&(1<<uint(chroot)) != 0 {
+			t.Errorf("Error on %+v, got CapBnd %v", tt.tr, status["CapBnd"])
+		}
+		if want := map[bool]string{false: "0", true: "1"}[tt.tr.NoNewPrivs]; status["NoNewPrivs"] != want {
+			t.Errorf("Error on %+v, got NoNewPrivs %v", tt.tr, status["NoNewPrivs"])
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package main
+
+import (
+	"os/exec"
+)
+
+func setupCgroup(cmd *exec.Cmd) error {
+	return nil
+}
+
+func removeCgroup() {}
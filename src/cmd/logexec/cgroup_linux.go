@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	cgroupParent = "/sys/fs/cgroup"
+	memoryMax    string
+	cpuMax       string
+
+	cgroupDir string
+	cgroupFd  *os.File
+)
+
+func init() {
+	flag.StringVar(&cgroupParent, "cgroup-parent", cgroupParent,
+		"cgroup v2 directory to create the child's cgroup in")
+	flag.StringVar(&memoryMax, "memory-max", "",
+		"memory.max limit for the child (e.g. 512M)")
+	flag.StringVar(&cpuMax, "cpu-max", "",
+		"cpu.max limit for the child as \"quota [period]\" in microseconds")
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// setupCgroup creates a transient cgroup carrying the requested limits and
+// arranges for cmd to be started directly inside it. All children share
+// the one cgroup.
+func setupCgroup(cmd *exec.Cmd) error {
+	if memoryMax == "" && cpuMax == "" {
+		return nil
+	}
+	if cgroupFd != nil {
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(cgroupFd.Fd())
+		return nil
+	}
+
+	var controllers []string
+	if memoryMax != "" {
+		controllers = append(controllers, "+memory")
+	}
+	if cpuMax != "" {
+		controllers = append(controllers, "+cpu")
+	}
+	err := writeCgroupFile(cgroupParent, "cgroup.subtree_control",
+		strings.Join(controllers, " "))
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(cgroupParent, fmt.Sprintf("logexec-%d", os.Getpid()))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	cgroupDir = dir
+
+	if memoryMax != "" {
+		if err := writeCgroupFile(dir, "memory.max", memoryMax); err != nil {
+			return err
+		}
+	}
+	if cpuMax != "" {
+		if err := writeCgroupFile(dir, "cpu.max", cpuMax); err != nil {
+			return err
+		}
+	}
+
+	cgroupFd, err = os.Open(dir)
+	if err != nil {
+		return err
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroupFd.Fd())
+	return nil
+}
+
+// removeCgroup deletes the transient cgroup once the child has exited.
+func removeCgroup() {
+	if cgroupDir == "" {
+		return
+	}
+	cgroupFd.Close()
+	cgroupFd = nil
+	if err := os.Remove(cgroupDir); err != nil {
+		warnf("Error removing cgroup %v: %v", cgroupDir, err)
+	}
+	cgroupDir = ""
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+)
+
+var checkOnly bool
+
+func init() {
+	flag.BoolVar(&checkOnly, "check", false,
+		"Check the commands, hooks and syslog connection, then exit without running anything")
+}
+
+// resolveCommand finds the executable that would be run for name.
+func resolveCommand(name string) (string, error) {
+	if chrootDir != "" {
+		return lookPathIn(chrootDir, name)
+	}
+	return exec.LookPath(name)
+}
+
+// runCheck verifies that specs could be started and logged, reporting
+// every problem found. It returns the exit status for -check.
+func runCheck(specs []runSpec) int {
+	var problems []string
+	for _, spec := range append(specs, stageSpecs...) {
+		if _, err := resolveCommand(spec.args[0]); err != nil {
+			problems = append(problems, fmt.Sprintf("command %s: %v", spec.name, err))
+		}
+	}
+	hooks := []struct{ kind, command string }{{"pre-exec", preExec}, {"post-exec", postExec}}
+	for _, h := range hooks {
+		kind, command := h.kind, h.command
+		if command == "" {
+			continue
+		}
+		args, err := splitArgs(command)
+		if err == nil && len(args) > 0 {
+			_, err = exec.LookPath(args[0])
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s hook %q: %v", kind, command, err))
+		}
+	}
+
+	problems = append(problems, checkDestinations()...)
+
+	for _, p := range problems {
+		log.Printf("Check failed: %s", p)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	log.Printf("Check passed")
+	return 0
+}
+
+// checkDestinations verifies that syslog and the spool, fallback and chroot
+// directories in use can be reached, returning the problems found.
+func checkDestinations() []string {
+	var problems []string
+	if w, err := UnixSyslog(stdoutPriority(), tag); err != nil {
+		problems = append(problems, fmt.Sprintf("syslog: %v", err))
+	} else {
+		w.Close()
+	}
+	if spoolDir != "" {
+		f, err := ioutil.TempFile(spoolDir, ".logexec-check")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("spool: %v", err))
+		} else {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+	if fallbackFile != "" {
+		f, err := os.OpenFile(fallbackFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("fallback file: %v", err))
+		} else {
+			f.Close()
+		}
+	}
+	if chrootDir != "" {
+		if fi, err := os.Stat(chrootDir); err != nil || !fi.IsDir() {
+			problems = append(problems, fmt.Sprintf("chroot: %s is not a directory", chrootDir))
+		}
+	}
+	return problems
+}
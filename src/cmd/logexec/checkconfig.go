@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checkConfig validates the configuration file at path: its syntax, that
+// every key in it and its profiles is known and every value valid, and that
+// the destinations it names can be reached. Each problem is printed on
+// stderr as path:line: message, and the exit status is 1 if there were any.
+func checkConfig(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	entries, err := parseConfig(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, configProblem(path, err))
+		return 1
+	}
+
+	var problems []string
+	for _, e := range entries {
+		e.key = e.key[strings.IndexByte(e.key, '.')+1:]
+		if err := applyConfigEntry(e, nil, setFlagValue); err != nil {
+			problems = append(problems, configProblem(path, err))
+		}
+	}
+	for _, p := range checkDestinations() {
+		problems = append(problems, path+": "+p)
+	}
+
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// configProblem formats an error from parsing or applying the configuration
+// file at path, with the line number if it has one.
+func configProblem(path string, err error) string {
+	if e, ok := err.(*configError); ok {
+		return fmt.Sprintf("%s:%d: %s", path, e.line, e.msg)
+	}
+	return fmt.Sprintf("%s: %v", path, err)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+var errInvalidUmask = errors.New("invalid umask, want an octal mode")
+
+// umaskValue is an octal file mode creation mask.
+type umaskValue struct {
+	mask int
+	set  bool
+}
+
+func (u umaskValue) String() string {
+	if !u.set {
+		return ""
+	}
+	return fmt.Sprintf("%04o", u.mask)
+}
+
+func (u *umaskValue) Set(to string) error {
+	v, err := strconv.ParseUint(to, 8, 32)
+	if err != nil || v > 0777 {
+		return errInvalidUmask
+	}
+	u.mask = int(v)
+	u.set = true
+	return nil
+}
+
+var (
+	niceness   optionalInt
+	childUmask umaskValue
+)
+
+func init() {
+	flag.Var(&niceness, "nice", "scheduling priority (niceness) for the child")
+	flag.Var(&childUmask, "umask", "file mode creation mask for the child")
+}
+
+// startChild starts cmd from a dedicated OS thread. Attributes that the
+// kernel tracks per thread are applied to that thread by setupThread, so
+// the child inherits them while logexec itself is left untouched; those it
+// tracks per process, which vary between systems, are left to the
+// trampoline of the child, as is the umask. The thread is never unlocked
+// and is discarded when the goroutine returns.
+func startChild(cmd *exec.Cmd) error {
+	return registerReaped(cmd, func() error {
+		errc := make(chan error, 1)
+		go func() {
+			runtime.LockOSThread()
+			if err := setupThread(); err != nil {
+				errc <- err
+				return
+			}
+			errc <- cmd.Start()
+		}()
+		return <-errc
+	})
+}
@@ -0,0 +1,39 @@
+// +build !linux
+
+package main
+
+import (
+	"os/exec"
+)
+
+func setupCommand(cmd *exec.Cmd) {}
+
+func setupThread() error {
+	return nil
+}
+
+// childTrampoline returns the settings the trampoline of each child applies.
+// The niceness is per process here rather than per thread, so it is set by
+// the trampoline instead of on the thread that forks the child.
+func childTrampoline() trampoline {
+	var t trampoline
+	if niceness.set {
+		t.Nice = &niceness.value
+	}
+	if childUmask.set {
+		t.Umask = &childUmask.mask
+	}
+	return t
+}
+
+func setLimits(limits []trampolineLimit) error {
+	return nil
+}
+
+func applyCapabilities(drop []string, noNewPrivs bool) error {
+	return nil
+}
+
+func writeOOMScoreAdj(adj int) error {
+	return nil
+}
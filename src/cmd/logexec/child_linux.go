@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	ioprioClassShift = 13
+	ioprioWhoProcess = 1
+)
+
+var errInvalidIOClass = errors.New("invalid I/O scheduling class")
+
+var ioClassByName = map[string]int{
+	"none":        0,
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+type ioClass int
+
+func (c ioClass) String() string {
+	for k, v := range ioClassByName {
+		if v == int(c) {
+			return k
+		}
+	}
+	return ""
+}
+
+func (c *ioClass) Set(to string) error {
+	v, ok := ioClassByName[to]
+	if !ok {
+		return errInvalidIOClass
+	}
+	*c = ioClass(v)
+	return nil
+}
+
+var (
+	ioSchedClass ioClass
+	ioSchedLevel optionalInt
+)
+
+func init() {
+	flag.Var(&ioSchedClass, "ioclass",
+		"I/O scheduling class for the child (realtime, best-effort, idle)")
+	flag.Var(&ioSchedLevel, "ionice",
+		"I/O scheduling priority for the child, 0 (highest) to 7 (lowest)")
+}
+
+// setupCommand sets clone time attributes on cmd.
+func setupCommand(cmd *exec.Cmd) {
+	setupPIDNamespace(cmd)
+}
+
+func setupThread() error {
+	if err := applyAffinity(); err != nil {
+		return err
+	}
+
+	if niceness.set {
+		err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, niceness.value)
+		if err != nil {
+			return fmt.Errorf("setting niceness: %v", err)
+		}
+	}
+
+	if ioSchedClass != 0 || ioSchedLevel.set {
+		class := ioSchedClass
+		if class == 0 {
+			class = ioClass(ioClassByName["best-effort"])
+		}
+		if ioSchedLevel.value < 0 || ioSchedLevel.value > 7 {
+			return fmt.Errorf("invalid I/O priority %d", ioSchedLevel.value)
+		}
+		prio := uintptr(class)<<ioprioClassShift | uintptr(ioSchedLevel.value)
+		_, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_SET,
+			ioprioWhoProcess, 0, prio)
+		if errno != 0 {
+			return fmt.Errorf("setting I/O priority: %v", errno)
+		}
+	}
+
+	return applyNamespaces()
+}
+
+// childTrampoline returns the settings the trampoline of each child applies.
+func childTrampoline() trampoline {
+	t := trampoline{
+		Limits:      trampolineLimits(),
+		DropCaps:    dropCaps,
+		NoNewPrivs:  noNewPrivs,
+		OOMScoreAdj: childOOMScoreAdj(),
+	}
+	if childUmask.set {
+		t.Umask = &childUmask.mask
+	}
+	return t
+}
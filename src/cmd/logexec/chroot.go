@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var chrootDir string
+
+func init() {
+	flag.StringVar(&chrootDir, "chroot", "", "directory to chroot the child into")
+}
+
+// setupChroot confines cmd to chrootDir. The syslog sockets are held by
+// logexec itself, so logging is unaffected. The command is resolved against
+// PATH inside the new root rather than on the host.
+func setupChroot(cmd *exec.Cmd, name string) error {
+	if chrootDir == "" {
+		return nil
+	}
+
+	path, err := lookPathIn(chrootDir, name)
+	if err != nil {
+		return err
+	}
+	cmd.Path = path
+	cmd.Err = nil
+	cmd.Dir = "/"
+	cmd.SysProcAttr.Chroot = chrootDir
+	return nil
+}
+
+func lookPathIn(root, file string) (string, error) {
+	if strings.Contains(file, "/") {
+		return file, nil
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		path := filepath.Join(dir, file)
+		fi, err := os.Stat(filepath.Join(root, path))
+		if err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
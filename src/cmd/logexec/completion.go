@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var errUnknownShell = errors.New("unknown shell, must be bash, zsh or fish")
+
+// subcommands lists the subcommands offered by the completion scripts.
+var subcommands = []string{"run", "bench", "check", "check-config", "install-launchd", "spool", "version"}
+
+// isBoolFlag reports whether f takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+// writeCompletion writes a completion script for shell to w, offering the
+// subcommands, every flag and then the commands and files to run.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return errUnknownShell
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer) {
+	var flags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, "-"+f.Name)
+	})
+	fmt.Fprintf(w, `_logexec() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	elif [[ $COMP_CWORD -eq 1 ]]; then
+		COMPREPLY=($(compgen -c -W "%s" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -c -f -- "$cur"))
+	fi
+}
+complete -F _logexec logexec
+`, strings.Join(flags, " "), strings.Join(subcommands, " "))
+}
+
+func writeZshCompletion(w io.Writer) {
+	escape := strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+	fmt.Fprintln(w, "#compdef logexec")
+	fmt.Fprintln(w, "_arguments -S \\")
+	flag.VisitAll(func(f *flag.Flag) {
+		if isBoolFlag(f) {
+			fmt.Fprintf(w, "\t'-%s[%s]' \\\n", f.Name, escape.Replace(f.Usage))
+		} else {
+			fmt.Fprintf(w, "\t'-%s+[%s]:value:' \\\n", f.Name, escape.Replace(f.Usage))
+		}
+	})
+	fmt.Fprintf(w, "\t'1: :(%s)' \\\n", strings.Join(subcommands, " "))
+	fmt.Fprintln(w, "\t'*:: :_normal'")
+}
+
+func writeFishCompletion(w io.Writer) {
+	escape := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	fmt.Fprintf(w, "complete -c logexec -n __fish_use_subcommand -a '%s'\n", strings.Join(subcommands, " "))
+	flag.VisitAll(func(f *flag.Flag) {
+		value := " -r"
+		if isBoolFlag(f) {
+			value = ""
+		}
+		fmt.Fprintf(w, "complete -c logexec -o %s -d '%s'%s\n", f.Name, escape.Replace(f.Usage), value)
+	})
+}
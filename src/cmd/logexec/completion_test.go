@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var b bytes.Buffer
+		if err := writeCompletion(&b, shell); err != nil || !strings.Contains(b.String(), "stdoutTag") {
+			t.Errorf("Error on %v, got %v, %q", shell, err, b.String())
+		}
+	}
+	if err := writeCompletion(new(bytes.Buffer), "ksh"); err != errUnknownShell {
+		t.Errorf("Error on ksh, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	configFile string
+	profile    string
+
+	// configCommand is the command given in the configuration file, run
+	// when none is given on the command line.
+	configCommand []string
+)
+
+func init() {
+	flag.StringVar(&configFile, "config", "",
+		"configuration file of flag = value lines; flags given on the command line take precedence")
+	flag.StringVar(&profile, "profile", "",
+		"profile in the configuration file whose settings override those outside any profile")
+}
+
+// configEntry is a key and its values from one line of a configuration
+// file.
+type configEntry struct {
+	line   int
+	key    string
+	values []string
+}
+
+// configError is a problem with a configuration file, located by line.
+type configError struct {
+	line int
+	msg  string
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// parseConfig reads a configuration file in a subset of TOML: lines of
+// key = value, where a value is a quoted string, a bare word such as a
+// number or true, or an array of those on one line. Comments start with #.
+// A [name] line starts a profile, and the keys that follow it are returned
+// as name.key.
+func parseConfig(r io.Reader) ([]configEntry, error) {
+	var entries []configEntry
+	var prefix string
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, &configError{n, "expected ] after profile name"}
+			}
+			name := strings.TrimSpace(line[1:end])
+			if name == "" || strings.ContainsAny(name, " \t.") {
+				return nil, &configError{n, fmt.Sprintf("invalid profile name %q", name)}
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != '#' {
+				return nil, &configError{n, fmt.Sprintf("unexpected %q after profile name", rest)}
+			}
+			prefix = name + "."
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, &configError{n, "expected key = value"}
+		}
+		key := strings.TrimSpace(line[:eq])
+		if key == "" {
+			return nil, &configError{n, "missing key"}
+		}
+		if strings.Contains(key, ".") {
+			return nil, &configError{n, fmt.Sprintf("invalid key %q", key)}
+		}
+		values, err := parseConfigValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, &configError{n, err.Error()}
+		}
+		entries = append(entries, configEntry{line: n, key: prefix + key, values: values})
+	}
+	return entries, s.Err()
+}
+
+// parseConfigValue parses a value or an array of values, followed by an
+// optional comment.
+func parseConfigValue(s string) ([]string, error) {
+	array := strings.HasPrefix(s, "[")
+	if array {
+		s = strings.TrimSpace(s[1:])
+	}
+
+	var values []string
+	for {
+		if array && strings.HasPrefix(s, "]") {
+			s = strings.TrimSpace(s[1:])
+			break
+		}
+		v, rest, err := nextConfigValue(s)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		s = strings.TrimSpace(rest)
+		if !array {
+			break
+		}
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if !strings.HasPrefix(s, "]") {
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+	if s != "" && s[0] != '#' {
+		return nil, fmt.Errorf("unexpected %q after value", s)
+	}
+	return values, nil
+}
+
+// nextConfigValue parses the value at the start of s and returns it along
+// with the rest of s.
+func nextConfigValue(s string) (string, string, error) {
+	switch {
+	case s == "":
+		return "", "", fmt.Errorf("missing value")
+	case s[0] == '"':
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				v, err := strconv.Unquote(s[:i+1])
+				return v, s[i+1:], err
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	case s[0] == '\'':
+		i := strings.IndexByte(s[1:], '\'')
+		if i < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : i+1], s[i+2:], nil
+	}
+	i := strings.IndexAny(s, " \t,]#")
+	if i < 0 {
+		i = len(s)
+	}
+	return s[:i], s[i:], nil
+}
+
+// loadConfig sets every flag in the configuration file at path that was
+// not given on the command line. The key command gives the command to run.
+func loadConfig(path string) error {
+	entries, err := configEntries(path)
+	if err != nil {
+		return err
+	}
+	set := flagsSet()
+	configProcessors.start = len(processors)
+	for _, e := range entries {
+		if err := applyConfigEntry(e, set, setFlagValue); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	configProcessors.end = len(processors)
+	return nil
+}
+
+// configEntries reads the configuration file at path and returns the
+// entries that apply, in the order they are applied. Keys in the profile
+// named by -profile come first, without the profile name, and take
+// precedence over keys outside any profile.
+func configEntries(path string) ([]configEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := parseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var applied []configEntry
+	inProfile := map[string]bool{}
+	found := false
+	for _, e := range entries {
+		if profile == "" || !strings.HasPrefix(e.key, profile+".") {
+			continue
+		}
+		found = true
+		e.key = strings.TrimPrefix(e.key, profile+".")
+		if !inProfile[e.key] {
+			applied = append(applied, e)
+		}
+		inProfile[e.key] = true
+	}
+	if profile != "" && !found {
+		return nil, fmt.Errorf("%s: no profile %q", path, profile)
+	}
+	for _, e := range entries {
+		if !strings.Contains(e.key, ".") && !inProfile[e.key] {
+			applied = append(applied, e)
+		}
+	}
+	return applied, nil
+}
+
+// flagsSet returns the names of the flags given on the command line or in
+// the environment, which take precedence over the configuration file.
+func flagsSet() map[string]bool {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// setFlagValue sets f to value, as the configuration file does at startup.
+func setFlagValue(f *flag.Flag, value string) error {
+	return f.Value.Set(value)
+}
+
+// applyConfigEntry sets the flag of e to its values with setValue, unless
+// it is in set.
+func applyConfigEntry(e configEntry, set map[string]bool, setValue func(f *flag.Flag, value string) error) error {
+	if e.key == "command" {
+		if !set[e.key] {
+			configCommand = e.values
+		}
+		return nil
+	}
+	f := flag.Lookup(e.key)
+	if f == nil || e.key == "config" || e.key == "profile" {
+		return &configError{e.line, fmt.Sprintf("unknown key %q", e.key)}
+	}
+	if set[e.key] {
+		return nil
+	}
+	for _, v := range e.values {
+		if err := setValue(f, v); err != nil {
+			return &configError{e.line, fmt.Sprintf("invalid value %q for %s: %v", v, e.key, err)}
+		}
+	}
+	return nil
+}
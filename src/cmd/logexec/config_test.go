@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		config string
+		want   []configEntry
+	}{
+		{"tag = web", []configEntry{{1, "tag", []string{"web"}}}},
+		{"# comment\n\ntag = \"my app\" # trailing", []configEntry{{3, "tag", []string{"my app"}}}},
+		{"stdin = 'null'", []configEntry{{1, "stdin", []string{"null"}}}},
+		{`run = ["a:echo a", 'b:echo "b"']`, []configEntry{{1, "run", []string{"a:echo a", `b:echo "b"`}}}},
+		{`command = ["sh", "-c", "echo \"hi\""]`, []configEntry{{1, "command", []string{"sh", "-c", `echo "hi"`}}}},
+		{"init = true\nmaxline=100", []configEntry{{1, "init", []string{"true"}}, {2, "maxline", []string{"100"}}}},
+		{"tag = web\n[batch] # jobs\ntag = job", []configEntry{{1, "tag", []string{"web"}}, {3, "batch.tag", []string{"job"}}}},
+	}
+	for _, tt := range tests {
+		got, err := parseConfig(strings.NewReader(tt.config))
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Error on %q, got %v, %v", tt.config, got, err)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"[batch", "line 1: expected ] after profile name"},
+		{"[]", `line 1: invalid profile name ""`},
+		{"[a.b]", `line 1: invalid profile name "a.b"`},
+		{"[batch] tag = x", `line 1: unexpected "tag = x" after profile name`},
+		{"a.tag = x", `line 1: invalid key "a.tag"`},
+		{"tag = web\nnothing", "line 2: expected key = value"},
+		{"= web", "line 1: missing key"},
+		{"tag =", "line 1: missing value"},
+		{`tag = "web`, "line 1: unterminated string"},
+		{`run = ["a" "b"]`, "line 1: expected , or ] in array"},
+		{"tag = web app", `line 1: unexpected "app" after value`},
+	}
+	for _, tt := range tests {
+		_, err := parseConfig(strings.NewReader(tt.config))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Error on %q, got %v", tt.config, err)
+		}
+	}
+}
+
+func TestConfigProblem(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&configError{3, `unknown key "x"`}, `a.toml:3: unknown key "x"`},
+		{errors.New("no such file"), "a.toml: no such file"},
+	}
+	for _, tt := range tests {
+		if got := configProblem("a.toml", tt.err); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.err, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"logexec"
+)
+
+const dockerTimeout = 5 * time.Second
+
+var (
+	containerMeta bool
+	dockerSocket  string
+
+	// cgroupContainerID matches the container ID at the end of a cgroup
+	// path, as in /docker/<id> or cri-containerd-<id>.scope.
+	cgroupContainerID = regexp.MustCompile(`([0-9a-f]{64})(\.scope)?$`)
+	// mountContainerID matches the container ID in the paths of the files
+	// Docker bind mounts into a container, such as /etc/hostname, which
+	// is the only place it shows with a private cgroup namespace.
+	mountContainerID = regexp.MustCompile(`/containers/([0-9a-f]{64})/`)
+)
+
+func init() {
+	flag.BoolVar(&containerMeta, "container", false,
+		"Append the ID of the container logexec runs in, found from its cgroup, to every line")
+	flag.StringVar(&dockerSocket, "docker-socket", "",
+		"Docker API socket to look up the container's name and labels on, e.g. /var/run/docker.sock; implies -container")
+}
+
+// containerID finds the container ID in the contents of /proc/self/cgroup,
+// or failing that of /proc/self/mountinfo. It returns "" outside a
+// container.
+func containerID(cgroup, mountinfo string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if m := cgroupContainerID.FindStringSubmatch(parts[2]); m != nil {
+			return m[1]
+		}
+	}
+	if m := mountContainerID.FindStringSubmatch(mountinfo); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// fieldValue quotes v if it would not otherwise read back as one value of
+// a key=value field.
+func fieldValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// enrichContainer adds the container fields to the processors when asked
+// to. Failing to find them is logged but does not stop the command.
+func enrichContainer() {
+	if !containerMeta && dockerSocket == "" {
+		return
+	}
+	cgroup, _ := ioutil.ReadFile("/proc/self/cgroup")
+	mountinfo, _ := ioutil.ReadFile("/proc/self/mountinfo")
+	id := containerID(string(cgroup), string(mountinfo))
+	if id == "" {
+		warnf("Error finding the container ID: not running in a container")
+		return
+	}
+	processors = append(processors, logexec.Enrich("container_id", id[:12]))
+	if dockerSocket == "" {
+		return
+	}
+
+	name, labels, err := inspectContainer(dockerSocket, id)
+	if err != nil {
+		warnf("Error looking up container %.12s: %v", id, err)
+		return
+	}
+	debugf("Found container %.12s named %s with %d labels", id, name, len(labels))
+	processors = append(processors, logexec.Enrich("container_name", fieldValue(name)))
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		processors = append(processors, logexec.Enrich("container_label_"+k, fieldValue(labels[k])))
+	}
+}
+
+// inspectContainer asks the Docker API on socket for the name and labels
+// of container id.
+func inspectContainer(socket, id string) (string, map[string]string, error) {
+	client := &http.Client{
+		Timeout: dockerTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	resp, err := client.Get("http://docker/containers/" + id + "/json")
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("docker API returned %s", resp.Status)
+	}
+	var info struct {
+		Name   string
+		Config struct {
+			Labels map[string]string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", nil, err
+	}
+	return strings.TrimPrefix(info.Name, "/"), info.Config.Labels, nil
+}
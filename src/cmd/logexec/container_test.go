@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testContainerID = "3f4e8c1d2b6a5f7e9d0c1b2a3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f2e"
+
+func TestContainerID(t *testing.T) {
+	tests := []struct {
+		cgroup, mountinfo string
+		want              string
+	}{
+		{"12:memory:/docker/" + testContainerID + "\n1:name=systemd:/docker/" + testContainerID + "\n", "", testContainerID},
+		{"0::/system.slice/docker-" + testContainerID + ".scope\n", "", testContainerID},
+		{"0::/kubepods/burstable/pod1234/cri-containerd-" + testContainerID + ".scope\n", "", testContainerID},
+		{"0::/\n", "1045 1040 254:1 /var/lib/docker/containers/" + testContainerID + "/hostname /etc/hostname rw - ext4 /dev/vda1 rw\n", testContainerID},
+		{"0::/user.slice/user-1000.slice/session-2.scope\n", "25 1 254:1 / / rw - ext4 /dev/vda1 rw\n", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := containerID(tt.cgroup, tt.mountinfo); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.cgroup, got)
+		}
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	tests := []struct {
+		v, want string
+	}{
+		{"web", "web"},
+		{"", `""`},
+		{"two words", `"two words"`},
+		{`a"b`, `"a\"b"`},
+		{"a=b", `"a=b"`},
+	}
+	for _, tt := range tests {
+		if got := fieldValue(tt.v); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.v, got)
+		}
+	}
+}
+
+func TestInspectContainer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/"+testContainerID+"/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"Name":"/web-1","Config":{"Labels":{"com.example.team":"infra"}}}`))
+	}))
+
+	name, labels, err := inspectContainer(socket, testContainerID)
+	want := map[string]string{"com.example.team": "infra"}
+	if err != nil || name != "web-1" || !reflect.DeepEqual(labels, want) {
+		t.Errorf("Error on %v, got %v, %v, %v", socket, name, labels, err)
+	}
+	if _, _, err := inspectContainer(socket, "missing"); err == nil {
+		t.Errorf("Error on missing container, got no error")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/syslog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"logexec"
+)
+
+var crashLines = 20
+
+func init() {
+	flag.IntVar(&crashLines, "crash-lines", crashLines,
+		"number of the last stderr lines to include in the crash report of a command killed by a core dumping signal (0 for no crash reports)")
+}
+
+// coreSignals are the signals whose default action is to dump core.
+var coreSignals = map[syscall.Signal]bool{
+	syscall.SIGQUIT: true,
+	syscall.SIGILL:  true,
+	syscall.SIGTRAP: true,
+	syscall.SIGABRT: true,
+	syscall.SIGBUS:  true,
+	syscall.SIGFPE:  true,
+	syscall.SIGSEGV: true,
+	syscall.SIGSYS:  true,
+	syscall.SIGXCPU: true,
+	syscall.SIGXFSZ: true,
+}
+
+// lastLines keeps the last lines written through it.
+type lastLines struct {
+	w io.Writer
+
+	mu    sync.Mutex
+	lines []string
+	next  int
+}
+
+func newLastLines(w io.Writer, n int) *lastLines {
+	if n < 0 {
+		n = 0
+	}
+	return &lastLines{w: w, lines: make([]string, 0, n)}
+}
+
+func (l *lastLines) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	switch {
+	case cap(l.lines) == 0:
+	case len(l.lines) < cap(l.lines):
+		l.lines = append(l.lines, string(b))
+	default:
+		l.lines[l.next] = string(b)
+		l.next = (l.next + 1) % len(l.lines)
+	}
+	l.mu.Unlock()
+	return l.w.Write(b)
+}
+
+// get returns the lines kept, oldest first.
+func (l *lastLines) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append(append([]string(nil), l.lines[l.next:]...), l.lines[:l.next]...)
+}
+
+// crashed reports whether c was killed by a signal that dumps core.
+func crashed(c *child) bool {
+	return coreSignals[c.signal]
+}
+
+// logCrashReport logs a crash report for c at crit level, giving the signal,
+// where the core dump went, and the last lines c wrote to stderr.
+func logCrashReport(c *child) {
+	core := "none"
+	if c.core {
+		core = corePath(c)
+	}
+	lines := c.stderrTail.get()
+	crashLog(c, fmt.Sprintf("Crash report: cmdline=%q pid=%d signal=%s core=%s stderr_lines=%d",
+		strings.Join(c.cmd.Args, " "), c.cmd.Process.Pid, signalName(c.signal), core, len(lines)))
+	for i, l := range lines {
+		crashLog(c, fmt.Sprintf("Crash report: stderr[%d]: %s", i+1, l))
+	}
+}
+
+// crashLog writes m to syslog and the sinks at crit level, bypassing the
+// processors so that the report cannot be dropped or rewritten.
+func crashLog(c *child, m string) {
+	c.stderr.writeLevel(syslog.LOG_CRIT, []byte(m))
+	r := logexec.Record{
+		Time:     time.Now(),
+		Tag:      c.stderr.tag,
+		Stream:   "stderr",
+		Priority: syslog.LOG_CRIT | stderrFacility.priority(),
+		Line:     []byte(m),
+	}
+	for _, s := range sinks {
+		s.mu.Lock()
+		err := s.sink.Write(r)
+		s.mu.Unlock()
+		if err != nil {
+			atomic.AddInt64(&sinkErrors, 1)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// +build !linux
+
+package main
+
+// corePath is not worked out outside Linux.
+func corePath(c *child) string {
+	return "unknown"
+}
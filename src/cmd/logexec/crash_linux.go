@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// corePath finds where the core dump of c went from the kernel's
+// core_pattern. A core piped to a program such as systemd-coredump is
+// given as the pattern.
+func corePath(c *child) string {
+	b, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		return "unknown"
+	}
+	pattern := strings.TrimSpace(string(b))
+	if strings.HasPrefix(pattern, "|") {
+		return strconv.Quote(pattern)
+	}
+	if b, err := ioutil.ReadFile("/proc/sys/kernel/core_uses_pid"); err == nil &&
+		strings.TrimSpace(string(b)) == "1" && !strings.Contains(pattern, "%p") {
+		pattern += ".%p"
+	}
+	host, _ := os.Hostname()
+	glob := expandCorePattern(pattern, c.cmd.Process.Pid, filepath.Base(c.path), c.signal, os.Getuid(), host)
+	if !filepath.IsAbs(glob) {
+		dir := c.cmd.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		glob = filepath.Join(dir, glob)
+	}
+	if chrootDir != "" {
+		glob = filepath.Join(chrootDir, glob)
+	}
+
+	// The newest match is the core, since specifiers that cannot be
+	// worked out here, such as the time, match anything.
+	matches, _ := filepath.Glob(glob)
+	var newest string
+	var newestTime int64
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err == nil && fi.ModTime().UnixNano() > newestTime {
+			newest, newestTime = m, fi.ModTime().UnixNano()
+		}
+	}
+	if newest == "" {
+		return strconv.Quote(glob)
+	}
+	return newest
+}
+
+// expandCorePattern expands the specifiers of a core_pattern, as described
+// in core(5), that logexec knows the value of. The others become *, so the
+// result is a glob.
+func expandCorePattern(pattern string, pid int, comm string, sig syscall.Signal, uid int, host string) string {
+	// The kernel truncates the command name to 15 bytes.
+	if len(comm) > 15 {
+		comm = comm[:15]
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'p':
+			b.WriteString(strconv.Itoa(pid))
+		case 'e':
+			b.WriteString(comm)
+		case 's':
+			b.WriteString(strconv.Itoa(int(sig)))
+		case 'u':
+			b.WriteString(strconv.Itoa(uid))
+		case 'h':
+			b.WriteString(host)
+		default:
+			b.WriteByte('*')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestExpandCorePattern(t *testing.T) {
+	tests := []struct {
+		pattern, want string
+	}{
+		{"core", "core"},
+		{"core.%p", "core.42"},
+		{"/var/crash/%e.%p.%s.%u.%h", "/var/crash/a-very-long-pro.42.11.1000.web1"},
+		{"/var/crash/core-%e-%t", "/var/crash/core-a-very-long-pro-*"},
+		{"100%%-%", "100%-%"},
+	}
+	for _, tt := range tests {
+		got := expandCorePattern(tt.pattern, 42, "a-very-long-program", syscall.SIGSEGV, 1000, "web1")
+		if got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.pattern, got)
+		}
+	}
+}
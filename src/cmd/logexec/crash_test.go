@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		n     int
+		lines []string
+		want  []string
+	}{
+		{3, []string{"a", "b"}, []string{"a", "b"}},
+		{3, []string{"a", "b", "c", "d", "e"}, []string{"c", "d", "e"}},
+		{1, []string{"a", "b"}, []string{"b"}},
+		{0, []string{"a", "b"}, nil},
+	}
+	for _, tt := range tests {
+		l := newLastLines(ioutil.Discard, tt.n)
+		for _, s := range tt.lines {
+			l.Write([]byte(s))
+		}
+		if got := l.get(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Error on %v, got %v", tt.lines, got)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import "flag"
+
+var debug bool
+
+func init() {
+	flag.BoolVar(&debug, "debug", false,
+		"Log logexec's own decisions, such as syslog connections and queue overflows")
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"logexec"
+)
+
+// devlogEnv is the variable the socket path is passed to the commands in,
+// for programs that can be told where to send their syslog messages.
+const devlogEnv = "LOGEXEC_SYSLOG_SOCKET"
+
+// devlogMaxMessage is the largest syslog(3) message read from the socket.
+// Longer ones are truncated by the kernel, and then to -maxline.
+const devlogMaxMessage = 64 << 10
+
+var (
+	devlog bool
+
+	// devlogPath is the socket of the current run, if -devlog is set.
+	devlogPath string
+)
+
+func init() {
+	flag.BoolVar(&devlog, "devlog", false,
+		"give the commands a private syslog socket and log what they send to it like their output")
+}
+
+// devlogIdent matches the ident and pid syslog(3) puts in front of the
+// message.
+var devlogIdent = regexp.MustCompile(`^[^\s:\[]+(\[[0-9]+\])?: `)
+
+// parseDevlog returns the priority and message of a datagram sent to
+// /dev/log in the format of syslog(3), <PRI>Mmm dd hh:mm:ss ident[pid]: msg.
+// The timestamp and ident are dropped, as the message is logged under the
+// -tag. A message without a facility, or without a priority at all, gets
+// the -facility.
+func parseDevlog(b []byte) (syslog.Priority, []byte) {
+	b = bytes.TrimRight(b, "\x00\n")
+	pri := syslog.LOG_NOTICE
+	if len(b) > 0 && b[0] == '<' {
+		if i := bytes.IndexByte(b, '>'); i > 1 && i <= 4 {
+			if n, err := strconv.Atoi(string(b[1:i])); err == nil && n>>3 < 24 {
+				pri = syslog.Priority(n)
+				b = b[i+1:]
+			}
+		}
+	}
+	if pri&^7 == 0 {
+		pri |= syslog.Priority(facility)
+	}
+	if len(b) > len(time.Stamp) && b[len(time.Stamp)] == ' ' {
+		if _, err := time.Parse(time.Stamp, string(b[:len(time.Stamp)])); err == nil {
+			b = b[len(time.Stamp)+1:]
+			if m := devlogIdent.Find(b); m != nil {
+				b = b[len(m):]
+			}
+		}
+	}
+	return pri, b
+}
+
+// devlogReader logs the messages sent to the -devlog socket, each at its
+// own priority.
+type devlogReader struct {
+	dir     string
+	conn    *net.UnixConn
+	writers map[syslog.Priority]io.Writer
+	spools  []*spoolWriter
+	done    chan struct{}
+}
+
+// startDevlog creates the socket for the commands of a run, in a directory
+// of its own that any user can reach it in, and starts reading it.
+func startDevlog() (*devlogReader, error) {
+	if !devlog {
+		return nil, nil
+	}
+	dir, err := os.MkdirTemp("", "logexec-devlog")
+	if err != nil {
+		return nil, fmt.Errorf("creating devlog socket: %v", err)
+	}
+	path := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err == nil {
+		err = os.Chmod(dir, 0755)
+	}
+	if err == nil {
+		err = os.Chmod(path, 0666)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("creating devlog socket: %v", err)
+	}
+	devlogPath = path
+	r := &devlogReader{
+		dir:     dir,
+		conn:    conn,
+		writers: map[syslog.Priority]io.Writer{},
+		done:    make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+func (r *devlogReader) run() {
+	defer close(r.done)
+	buf := make([]byte, devlogMaxMessage)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				warnf("Error reading devlog socket: %v", err)
+			}
+			return
+		}
+		touchOutput()
+		pri, msg := parseDevlog(buf[:n])
+		w := r.writer(pri)
+		if w == nil {
+			continue
+		}
+		lw := logexec.NewLineWriter(w, logexec.LineOptions{MaxLine: *maxLogLine})
+		lw.Write(msg)
+		lw.Flush()
+	}
+}
+
+// writer returns the writer for messages at pri, opening a syslog
+// connection for it the first time it is used.
+func (r *devlogReader) writer(pri syslog.Priority) io.Writer {
+	if w, ok := r.writers[pri]; ok {
+		return w
+	}
+	log, err := UnixSyslog(pri, tag)
+	if err != nil {
+		errorf("Error initializing devlog syslog: %v", err)
+		return nil
+	}
+	sw, err := newSpoolWriter(log, tag, "devlog")
+	if err != nil {
+		log.Close()
+		errorf("Error %v", err)
+		return nil
+	}
+	r.spools = append(r.spools, sw)
+	w := newRecordWriter(sw, tag, "devlog", pri)
+	r.writers[pri] = w
+	return w
+}
+
+// stopDevlog logs the messages left in the socket and removes it.
+func stopDevlog(r *devlogReader) {
+	if r == nil {
+		return
+	}
+	r.conn.SetReadDeadline(time.Now().Add(fifoDrainTime))
+	<-r.done
+	r.conn.Close()
+	os.RemoveAll(r.dir)
+	devlogPath = ""
+	for _, sw := range r.spools {
+		sw.Close()
+	}
+}
+
+// passDevlog tells cmd where the -devlog socket is.
+func passDevlog(cmd *exec.Cmd) {
+	if devlogPath == "" {
+		return
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, devlogEnv+"="+devlogPath)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log/syslog"
+	"testing"
+)
+
+func TestParseDevlog(t *testing.T) {
+	local0 := syslog.Priority(facility)
+	tests := []struct {
+		in   string
+		pri  syslog.Priority
+		want string
+	}{
+		{"<27>Oct 16 09:04:45 app[123]: disk full\n", syslog.LOG_DAEMON | syslog.LOG_ERR, "disk full"},
+		{"<14>Oct  6 09:04:45 app: started", syslog.LOG_USER | syslog.LOG_INFO, "started"},
+		{"<3>Oct 16 09:04:45 kernel-ish: no facility", local0 | syslog.LOG_ERR, "no facility"},
+		{"<13>Oct 16 09:04:45 no ident here", syslog.LOG_USER | syslog.LOG_NOTICE, "no ident here"},
+		{"<13>error: no timestamp", syslog.LOG_USER | syslog.LOG_NOTICE, "error: no timestamp"},
+		{"plain message\x00", local0 | syslog.LOG_NOTICE, "plain message"},
+		{"<999>bad priority", local0 | syslog.LOG_NOTICE, "<999>bad priority"},
+	}
+	for _, tt := range tests {
+		pri, got := parseDevlog([]byte(tt.in))
+		if pri != tt.pri || string(got) != tt.want {
+			t.Errorf("Error on %q, got %v %q", tt.in, pri, got)
+		}
+	}
+}
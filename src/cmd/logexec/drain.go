@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+var drainTimeout time.Duration
+
+func init() {
+	flag.DurationVar(&drainTimeout, "drain-timeout", 5*time.Second,
+		"how long to keep logging output after the command exits, for processes it left holding its stdout or stderr (0 waits forever)")
+}
+
+// startDrain returns a channel that fires when the drain timeout expires,
+// or nil if output should be drained until EOF however long it takes.
+func startDrain() <-chan time.Time {
+	if drainTimeout <= 0 {
+		return nil
+	}
+	return time.After(drainTimeout)
+}
+
+// closePipes closes the read ends of the children's output pipes. Loggers
+// still reading from them return with os.ErrClosed.
+func closePipes(children []*child) {
+	for _, c := range children {
+		c.stopOutput()
+		for _, p := range c.pipes {
+			p.Close()
+		}
+		c.pipes = nil
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var dryRun bool
+
+func init() {
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Print the effective configuration after applying the configuration file and environment, then exit")
+}
+
+// printConfig writes every flag with its effective value, followed by
+// where the output of each of specs would be logged.
+func printConfig(w io.Writer, specs []runSpec) {
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "dry-run" {
+			return
+		}
+		fmt.Fprintf(w, "%s = %s\n", f.Name, strconv.Quote(f.Value.String()))
+	})
+
+	for _, spec := range append(specs, stageSpecs...) {
+		quoted := make([]string, len(spec.args))
+		for i, arg := range spec.args {
+			quoted[i] = strconv.Quote(arg)
+		}
+		outTag, errTag := spec.tags()
+		fmt.Fprintf(w, "\n# %s\n", spec.name)
+		fmt.Fprintf(w, "# command = [%s]\n", strings.Join(quoted, ", "))
+		fmt.Fprintf(w, "# stdout: tag=%s facility=%v level=%v\n",
+			outTag, logFacility(stdoutFacility.priority()), stdoutLevel)
+		fmt.Fprintf(w, "# stderr: tag=%s facility=%v level=%v\n",
+			errTag, logFacility(stderrFacility.priority()), stderrLevel)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log/syslog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+var dumpSignal = signalFlag(syscall.SIGUSR2)
+
+func init() {
+	flag.Var(&dumpSignal, "dump-signal",
+		"signal that makes logexec log its internal state instead of passing it on, or none")
+}
+
+// dumpState logs the counters, queue and syslog state and the stacks of
+// all goroutines, for debugging a wedged logexec without restarting the
+// command.
+func dumpState(children []*child) {
+	var pids []string
+	for _, c := range children {
+		pids = append(pids, strconv.Itoa(c.cmd.Process.Pid))
+	}
+	totals := streamTotals()
+	selfLogf(syslog.LOG_NOTICE,
+		"State dump: pids=%s running=%d queue_depth=%d queue_high_water=%d failing_writers=%d sink_errors=%d reconnects=%d runs=%d",
+		strings.Join(pids, ","), atomic.LoadInt64(&childrenRunning), queueDepth(),
+		atomic.LoadInt64(&queueHighWater), atomic.LoadInt64(&failingWriters),
+		atomic.LoadInt64(&sinkErrors), atomic.LoadInt64(&reconnects), atomic.LoadInt64(&runs))
+	for i, s := range outputStreams() {
+		selfLogf(syslog.LOG_NOTICE, "State dump: %s lines=%d bytes=%d dropped=%d truncated=%d",
+			s.name, totals[i].Lines, totals[i].Bytes, totals[i].dropped, totals[i].Truncated)
+	}
+
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		selfLogf(syslog.LOG_NOTICE, "State dump: %s", line)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envName returns the environment variable that sets the flag name, such
+// as LOGEXEC_DRAIN_TIMEOUT for -drain-timeout.
+func envName(name string) string {
+	return "LOGEXEC_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// loadEnv sets every flag that was not given on the command line from its
+// environment variable, if that is set. The variables are removed from the
+// environment, so that a logexec run by the commands does not take the
+// settings meant for this one, such as its -pidfile or -state-file; those
+// passed on deliberately, such as LOGEXEC_RUN_ID, are set again later.
+func loadEnv() error {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		os.Unsetenv(envName(f.Name))
+		if set[f.Name] || err != nil {
+			return
+		}
+		if e := flag.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", v, envName(f.Name), e)
+		}
+	})
+	return err
+}
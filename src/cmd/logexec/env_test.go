@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"tag", "LOGEXEC_TAG"},
+		{"stdoutLevel", "LOGEXEC_STDOUTLEVEL"},
+		{"drain-timeout", "LOGEXEC_DRAIN_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		if got := envName(tt.name); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.name, got)
+		}
+	}
+}
+
+func TestLoadEnvUnsets(t *testing.T) {
+	defer func(q bool) { quiet = q }(quiet)
+	os.Setenv("LOGEXEC_QUIET", "true")
+	os.Setenv("LOGEXEC_NOT_AN_OPTION", "x")
+	defer os.Unsetenv("LOGEXEC_NOT_AN_OPTION")
+	if err := loadEnv(); err != nil || !quiet {
+		t.Errorf("Error on LOGEXEC_QUIET, got %v, %v", quiet, err)
+	}
+	if v, ok := os.LookupEnv("LOGEXEC_QUIET"); ok {
+		t.Errorf("Error on LOGEXEC_QUIET, got %q left in the environment", v)
+	}
+	if _, ok := os.LookupEnv("LOGEXEC_NOT_AN_OPTION"); !ok {
+		t.Errorf("Error on LOGEXEC_NOT_AN_OPTION, got it removed")
+	}
+}
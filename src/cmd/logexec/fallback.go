@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	fallbackFile string
+
+	fallbackMu    sync.Mutex
+	fallback      *os.File
+	fallbackDown  bool
+	fallbackLines int64
+)
+
+func init() {
+	flag.StringVar(&fallbackFile, "fallback-file", "",
+		"file to append output to while syslog is unavailable, when not spooling")
+}
+
+// writeFallback appends a line from the stream of tag to the fallback file,
+// preceded by a marker if syslog has only just become unavailable.
+func writeFallback(tag, stream string, b []byte, cause error) error {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	if fallback == nil {
+		f, err := os.OpenFile(fallbackFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		fallback = f
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if !fallbackDown {
+		if _, err := fmt.Fprintf(fallback, "%s --- syslog unavailable, logging here: %v ---\n", now, cause); err != nil {
+			return err
+		}
+		fallbackDown = true
+	}
+	if _, err := fmt.Fprintf(fallback, "%s %s[%s]: %s\n", now, tag, stream, b); err != nil {
+		return err
+	}
+	fallbackLines++
+	return nil
+}
+
+// fallbackRecovered marks the end of an outage in the fallback file and in
+// syslog through w, if output has been going to the fallback file.
+func fallbackRecovered(w io.Writer) {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	if !fallbackDown {
+		return
+	}
+	fmt.Fprintf(fallback, "%s --- syslog available again ---\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(w, "Logged %d lines to %s while syslog was unavailable", fallbackLines, fallbackFile)
+	fallbackDown = false
+	fallbackLines = 0
+}
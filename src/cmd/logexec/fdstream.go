@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/syslog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// fdStream is an extra pipe passed to the main command at fd, whose lines
+// are logged under their own tag and priority and counted like those of
+// stdout and stderr. past holds the totals of its previous runs.
+type fdStream struct {
+	fd       int
+	tag      string
+	level    logLevel
+	facility streamFacility
+
+	past *streamStats
+}
+
+// name is the name of the stream in markers and statistics.
+func (s fdStream) name() string {
+	return "fd" + strconv.Itoa(s.fd)
+}
+
+func (s fdStream) priority() syslog.Priority {
+	return syslog.Priority(s.level) | s.facility.priority()
+}
+
+func (s fdStream) String() string {
+	p := s.tag + ":" + s.level.String()
+	if s.facility.set {
+		p = s.tag + ":" + s.facility.String() + "." + s.level.String()
+	}
+	return strconv.Itoa(s.fd) + ":" + p
+}
+
+// fdList collects repeated -fd fd:tag[:[facility.]level] streams.
+type fdList []fdStream
+
+func (l fdList) String() string {
+	var s []string
+	for _, f := range l {
+		s = append(s, f.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func (l *fdList) Set(to string) error {
+	s, err := parseFdStream(to)
+	if err != nil {
+		return err
+	}
+	for _, f := range *l {
+		if f.fd == s.fd {
+			return fmt.Errorf("fd %d given twice", s.fd)
+		}
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+var fdStreams fdList
+
+func init() {
+	flag.Var(&fdStreams, "fd",
+		"extra pipe for the command as fd:tag[:[facility.]level], e.g. 3:audit:authpriv.notice, logged like stdout; may be repeated")
+}
+
+// parseFdStream parses fd:tag[:[facility.]level].
+func parseFdStream(to string) (fdStream, error) {
+	s := fdStream{
+		level: logLevel(syslog.LOG_INFO),
+		past:  &streamStats{},
+	}
+	parts := strings.Split(to, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return s, fmt.Errorf("invalid fd stream %q, must be fd:tag[:[facility.]level]", to)
+	}
+	fd, err := strconv.Atoi(parts[0])
+	if err != nil || fd < 3 {
+		return s, fmt.Errorf("invalid fd %q, must be 3 or more", parts[0])
+	}
+	s.fd, s.tag = fd, parts[1]
+	if len(parts) == 2 {
+		return s, nil
+	}
+	return s, parsePriority(parts[2], &s.level, &s.facility)
+}
+
+// setupFdStreams creates a pipe for each -fd stream, passing the write end
+// to cmd and logging what is read from the other end once cmd has started.
+// The writers and loggers are added to c, and the write ends returned to be
+// closed once cmd has started, along with those created before an error.
+func (run *runState) setupFdStreams(cmd *exec.Cmd, c *child) ([]*os.File, error) {
+	var childEnds []*os.File
+	for _, s := range fdStreams {
+		i := s.fd - 3
+		for len(cmd.ExtraFiles) <= i {
+			cmd.ExtraFiles = append(cmd.ExtraFiles, nil)
+		}
+		if cmd.ExtraFiles[i] != nil {
+			return childEnds, fmt.Errorf("setting up fd %d: taken by a socket activation fd", s.fd)
+		}
+		log, err := UnixSyslog(s.priority(), s.tag)
+		if err != nil {
+			return childEnds, fmt.Errorf("initializing fd %d syslog: %v", s.fd, err)
+		}
+		stream := s.name()
+		sw, err := newSpoolWriter(log, s.tag, stream)
+		if err != nil {
+			log.Close()
+			return childEnds, err
+		}
+		c.streams = append(c.streams, sw)
+
+		r, w, err := os.Pipe()
+		if err != nil {
+			return childEnds, fmt.Errorf("initializing fd %d pipe: %v", s.fd, err)
+		}
+		cmd.ExtraFiles[i] = w
+		childEnds = append(childEnds, w)
+		c.pipes = append(c.pipes, r)
+		tag, priority, stats := s.tag, s.priority(), run.stats(stream)
+		c.logs = append(c.logs, func() {
+			run.logPipe(newRecordWriter(sw, tag, stream, priority), r, stats)
+		})
+	}
+	return childEnds, nil
+}
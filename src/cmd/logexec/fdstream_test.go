@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log/syslog"
+	"strings"
+	"testing"
+)
+
+func TestParseFdStream(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     string
+		priority syslog.Priority
+		ok       bool
+	}{
+		{"3:audit:LOG_AUTHPRIV.notice", "3:audit:authpriv.notice", syslog.LOG_AUTHPRIV | syslog.LOG_NOTICE, true},
+		{"4:metrics", "4:metrics:info", syslog.Priority(facility) | syslog.LOG_INFO, true},
+		{"5:trace:debug", "5:trace:debug", syslog.Priority(facility) | syslog.LOG_DEBUG, true},
+		{"3:audit:LOCAL3.WARNING", "3:audit:local3.warning", syslog.LOG_LOCAL3 | syslog.LOG_WARNING, true},
+		{"2:audit", "", 0, false},
+		{"x:audit", "", 0, false},
+		{"3:", "", 0, false},
+		{"3", "", 0, false},
+		{"3:audit:loud", "", 0, false},
+		{"3:audit:nowhere.info", "", 0, false},
+		{"3:audit:info:extra", "", 0, false},
+	}
+	for _, tt := range tests {
+		s, err := parseFdStream(tt.in)
+		if (err == nil) != tt.ok || err == nil && (s.String() != tt.want || s.priority() != tt.priority) {
+			t.Errorf("Error on %v, got %v, %v", tt.in, s, err)
+		}
+	}
+
+	var l fdList
+	if err := l.Set("3:a"); err != nil {
+		t.Errorf("Error on 3:a, got %v", err)
+	}
+	if err := l.Set("3:b"); err == nil {
+		t.Errorf("Error on 3:b, got no error for a repeated fd")
+	}
+}
+
+func TestFdStreamStats(t *testing.T) {
+	defer func(l fdList) { fdStreams = l }(fdStreams)
+	fdStreams = nil
+	for _, s := range []string{"3:audit", "5:trace:debug"} {
+		if err := fdStreams.Set(s); err != nil {
+			t.Fatalf("Error on %v: %v", s, err)
+		}
+	}
+	defer func(run *runState) { currentRun = run }(currentRun)
+	currentRun = newRunState(context.Background())
+	currentRun.stats("fd5").Lines, fdStreams[1].past.Lines = 2, 3
+
+	var names []string
+	for _, s := range outputStreams() {
+		names = append(names, s.name)
+	}
+	if got := strings.Join(names, ","); got != "stdout,stderr,fd3,fd5" {
+		t.Errorf("Error on stream names, got %v", got)
+	}
+	if totals := streamTotals(); totals[3].Lines != 5 || totals[2].Lines != 0 {
+		t.Errorf("Error on fd totals, got %+v", totals)
+	}
+}
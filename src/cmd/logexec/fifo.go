@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/syslog"
+	"os"
+	"strings"
+	"time"
+
+	"logexec"
+)
+
+// fifoDrainTime is how long the FIFOs are read for once the commands have
+// exited, for lines still in them.
+const fifoDrainTime = 100 * time.Millisecond
+
+// fifoInput is an existing named pipe whose lines are logged with the -tag
+// at its own priority.
+type fifoInput struct {
+	path     string
+	level    logLevel
+	facility streamFacility
+}
+
+func (in fifoInput) priority() syslog.Priority {
+	return syslog.Priority(in.level) | in.facility.priority()
+}
+
+// fifoList collects repeated -fifo path[:[facility.]level] inputs.
+type fifoList []fifoInput
+
+func (l fifoList) String() string {
+	var s []string
+	for _, in := range l {
+		s = append(s, in.path)
+	}
+	return strings.Join(s, ", ")
+}
+
+func (l *fifoList) Set(to string) error {
+	in := fifoInput{path: to, level: logLevel(syslog.LOG_INFO)}
+	if i := strings.LastIndexByte(to, ':'); i >= 0 {
+		in.path = to[:i]
+		if err := parsePriority(to[i+1:], &in.level, &in.facility); err != nil {
+			return err
+		}
+	}
+	if in.path == "" {
+		return fmt.Errorf("invalid fifo %q, must be path[:[facility.]level]", to)
+	}
+	*l = append(*l, in)
+	return nil
+}
+
+var fifoInputs fifoList
+
+func init() {
+	flag.Var(&fifoInputs, "fifo",
+		"existing named pipe to log the lines written to as path[:[facility.]level], e.g. /run/app/log.pipe:info; may be repeated")
+}
+
+// fifoReader logs the lines written to a FIFO by any number of writers.
+type fifoReader struct {
+	in   fifoInput
+	f    *os.File
+	sw   *spoolWriter
+	q    *lineQueue
+	done chan struct{}
+}
+
+// startFifos starts reading the -fifo inputs. The FIFOs are opened for
+// writing as well as reading, so that they do not reach end of file when
+// their writers close them and open them again. If it fails, those already
+// read are stopped.
+func startFifos() ([]*fifoReader, error) {
+	var readers []*fifoReader
+	for _, in := range fifoInputs {
+		r, err := startFifo(in)
+		if err != nil {
+			stopFifos(readers)
+			return nil, err
+		}
+		readers = append(readers, r)
+	}
+	return readers, nil
+}
+
+func startFifo(in fifoInput) (*fifoReader, error) {
+	fi, err := os.Stat(in.path)
+	if err != nil {
+		return nil, fmt.Errorf("opening fifo: %v", err)
+	}
+	if fi.Mode()&os.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("opening fifo: %s is not a named pipe", in.path)
+	}
+	f, err := os.OpenFile(in.path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening fifo: %v", err)
+	}
+	log, err := UnixSyslog(in.priority(), tag)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("initializing fifo syslog: %v", err)
+	}
+	sw, err := newSpoolWriter(log, tag, "fifo")
+	if err != nil {
+		log.Close()
+		f.Close()
+		return nil, err
+	}
+	r := &fifoReader{in: in, f: f, sw: sw, done: make(chan struct{})}
+	r.q = newLineQueue(newRecordWriter(r.sw, tag, "fifo", in.priority()), &streamStats{})
+	go r.run()
+	return r, nil
+}
+
+func (r *fifoReader) run() {
+	defer close(r.done)
+	lw := logexec.NewLineWriter(r.q, logexec.LineOptions{MaxLine: *maxLogLine})
+	_, err := lw.ReadFrom(throttled(r.f))
+	lw.Flush()
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		warnf("Error reading fifo %s: %v", r.in.path, err)
+	}
+}
+
+// stopFifos logs the lines left in the FIFOs and stops reading them.
+func stopFifos(readers []*fifoReader) {
+	for _, r := range readers {
+		r.f.SetReadDeadline(time.Now().Add(fifoDrainTime))
+	}
+	for _, r := range readers {
+		<-r.done
+		r.f.Close()
+		r.q.Close()
+		r.sw.Close()
+	}
+}
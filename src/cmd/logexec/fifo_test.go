@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log/syslog"
+	"path/filepath"
+	"testing"
+)
+
+func TestFifoListSet(t *testing.T) {
+	tests := []struct {
+		in       string
+		path     string
+		priority syslog.Priority
+		ok       bool
+	}{
+		{"/run/app/log.pipe:INFO", "/run/app/log.pipe", syslog.Priority(facility) | syslog.LOG_INFO, true},
+		{"/run/app/log.pipe", "/run/app/log.pipe", syslog.Priority(facility) | syslog.LOG_INFO, true},
+		{"/run/app/err.pipe:LOG_DAEMON.err", "/run/app/err.pipe", syslog.LOG_DAEMON | syslog.LOG_ERR, true},
+		{"/run/app/log.pipe:loud", "", 0, false},
+		{":info", "", 0, false},
+	}
+	for _, tt := range tests {
+		var l fifoList
+		err := l.Set(tt.in)
+		if (err == nil) != tt.ok || err == nil && (l[0].path != tt.path || l[0].priority() != tt.priority) {
+			t.Errorf("Error on %v, got %v, %v", tt.in, l, err)
+		}
+	}
+}
+
+func TestStartFifosError(t *testing.T) {
+	defer func(l fifoList) { fifoInputs = l }(fifoInputs)
+	dir := t.TempDir()
+	tests := []string{
+		filepath.Join(dir, "missing"),
+		dir,
+	}
+	for _, path := range tests {
+		fifoInputs = nil
+		if err := fifoInputs.Set(path); err != nil {
+			t.Fatalf("Error on %v: %v", path, err)
+		}
+		if readers, err := startFifos(); err == nil || readers != nil {
+			t.Errorf("Error on %v, got %v, %v", path, readers, err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strconv"
+)
+
+// optionalInt is an integer flag that records whether it was given, for
+// settings where zero is a meaningful value distinct from "leave alone".
+type optionalInt struct {
+	value int
+	set   bool
+}
+
+func (o optionalInt) String() string {
+	if !o.set {
+		return ""
+	}
+	return strconv.Itoa(o.value)
+}
+
+func (o *optionalInt) Set(to string) error {
+	v, err := strconv.Atoi(to)
+	if err != nil {
+		return err
+	}
+	o.value = v
+	o.set = true
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"sync/atomic"
+)
+
+var healthAddr string
+
+func init() {
+	flag.StringVar(&healthAddr, "health-addr", "",
+		"address to serve a health check on at /healthz, e.g. :8080")
+}
+
+type health struct {
+	ChildRunning  bool `json:"child_running"`
+	SinkConnected bool `json:"sink_connected"`
+	QueueDepth    int  `json:"queue_depth"`
+}
+
+// startHealth serves the health endpoint if -health-addr is set.
+func startHealth() {
+	if healthAddr != "" {
+		handle(healthAddr, "/healthz", serveHealth)
+	}
+}
+
+// serveHealth reports the state of the command and of syslog. It fails
+// while syslog cannot be written to, or when the command is not running
+// outside of the pauses between scheduled runs.
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	h := health{
+		ChildRunning:  atomic.LoadInt64(&childrenRunning) > 0,
+		SinkConnected: atomic.LoadInt64(&failingWriters) == 0,
+		QueueDepth:    queueDepth(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !h.SinkConnected || (!h.ChildRunning && every == 0) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(h)
+}
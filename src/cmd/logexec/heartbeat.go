@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var heartbeat time.Duration
+
+func init() {
+	flag.DurationVar(&heartbeat, "heartbeat", 0,
+		"log a still running message at this interval (e.g. 10m)")
+}
+
+// startHeartbeat returns a channel that ticks at the heartbeat interval, or
+// nil if heartbeats are disabled.
+func startHeartbeat() <-chan time.Time {
+	if heartbeat <= 0 {
+		return nil
+	}
+	return time.NewTicker(heartbeat).C
+}
+
+func (run *runState) heartbeatMessage(children []*child) string {
+	var pids []string
+	for _, c := range children {
+		pids = append(pids, strconv.Itoa(c.cmd.Process.Pid))
+	}
+	lines := atomic.LoadInt64(&run.stats("stdout").Lines) + atomic.LoadInt64(&run.stats("stderr").Lines)
+	return fmt.Sprintf("still running, pid=%s, uptime=%v, lines=%d",
+		strings.Join(pids, ","), time.Since(run.start).Round(time.Second), lines)
+}
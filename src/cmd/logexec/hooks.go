@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log/syslog"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"logexec"
+)
+
+var (
+	preExec  string
+	postExec string
+)
+
+func init() {
+	flag.StringVar(&preExec, "pre-exec", "",
+		"command to run before starting; a failure aborts the run")
+	flag.StringVar(&postExec, "post-exec", "",
+		"command to run after the command exits, with its status in the environment")
+}
+
+func logLines(w *syslog.Writer, b []byte) {
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if l := bytes.TrimSpace(line); len(l) > 0 {
+			w.Write(l)
+		}
+	}
+}
+
+// runHook runs command with env added to logexec's environment, logs its
+// output and returns its exit status.
+func runHook(kind, command string, env []string) int {
+	args, err := splitArgs(command)
+	if err != nil || len(args) == 0 {
+		warnf("Invalid %s hook %q: %v", kind, command, err)
+		return 1
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = startReaped(cmd); err == nil {
+		err = waitReaped(cmd)
+	}
+	logLines(stdoutLog, stdout.Bytes())
+	logLines(stderrLog, stderr.Bytes())
+
+	status := logexec.ExitStatus(err)
+	if status != 0 {
+		warnf("%s hook %q failed: %v", kind, command, err)
+	}
+	return status
+}
+
+func runPreExec() int {
+	if preExec == "" {
+		return 0
+	}
+	return runHook("pre-exec", preExec, []string{"LOGEXEC_HOOK_TAG=" + tag})
+}
+
+func runPostExec(run *runState, status int) {
+	if postExec == "" {
+		return
+	}
+	runHook("post-exec", postExec, postExecEnv(run, status, time.Since(run.start)))
+}
+
+// postExecEnv returns the environment describing run, which took duration
+// and exited with status, to the post-exec hook. The variables are named
+// apart from those of the options, so that a logexec run by the hook does
+// not take them as its own settings.
+func postExecEnv(run *runState, status int, duration time.Duration) []string {
+	stdout, stderr := run.stats("stdout"), run.stats("stderr")
+	return []string{
+		"LOGEXEC_HOOK_TAG=" + tag,
+		"LOGEXEC_HOOK_EXIT_STATUS=" + strconv.Itoa(status),
+		"LOGEXEC_HOOK_START_TIME=" + run.start.Format(time.RFC3339),
+		"LOGEXEC_HOOK_DURATION=" + strconv.FormatFloat(duration.Seconds(), 'f', 3, 64),
+		"LOGEXEC_HOOK_STDOUT_LINES=" + strconv.FormatInt(atomic.LoadInt64(&stdout.Lines), 10),
+		"LOGEXEC_HOOK_STDOUT_BYTES=" + strconv.FormatInt(atomic.LoadInt64(&stdout.Bytes), 10),
+		"LOGEXEC_HOOK_STDERR_LINES=" + strconv.FormatInt(atomic.LoadInt64(&stderr.Lines), 10),
+		"LOGEXEC_HOOK_STDERR_BYTES=" + strconv.FormatInt(atomic.LoadInt64(&stderr.Bytes), 10),
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostExecEnv(t *testing.T) {
+	defer func(tg string) { tag = tg }(tag)
+	tag = "job"
+	run := newRunState(context.Background())
+	run.start = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	run.stats("stdout").Lines, run.stats("stdout").Bytes = 3, 30
+	run.stats("stderr").Lines, run.stats("stderr").Bytes = 1, 10
+
+	env := postExecEnv(run, 2, 1500*time.Millisecond)
+	tests := []string{
+		"LOGEXEC_HOOK_TAG=job",
+		"LOGEXEC_HOOK_EXIT_STATUS=2",
+		"LOGEXEC_HOOK_START_TIME=2026-01-02T03:04:05Z",
+		"LOGEXEC_HOOK_DURATION=1.500",
+		"LOGEXEC_HOOK_STDOUT_LINES=3",
+		"LOGEXEC_HOOK_STDOUT_BYTES=30",
+		"LOGEXEC_HOOK_STDERR_LINES=1",
+		"LOGEXEC_HOOK_STDERR_BYTES=10",
+	}
+	if len(env) != len(tests) {
+		t.Errorf("Error on environment, got %q", env)
+	}
+	for i, want := range tests {
+		if i >= len(env) || env[i] != want {
+			t.Errorf("Error on %v, got %q", want, env)
+		}
+	}
+
+	// A logexec run by the hook must not take the variables as options.
+	flag.VisitAll(func(f *flag.Flag) {
+		for _, v := range env {
+			if strings.HasPrefix(v, envName(f.Name)+"=") {
+				t.Errorf("Error on %v, got the variable of -%s", v, f.Name)
+			}
+		}
+	})
+}
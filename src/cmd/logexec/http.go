@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net"
+	"net/http"
+)
+
+// servers holds the mux of each address listened on, so that endpoints
+// configured with the same address share one listener.
+var servers = map[string]*http.ServeMux{}
+
+// handle serves h at path on addr, listening on addr first if needed.
+func handle(addr, path string, h http.HandlerFunc) {
+	mux, ok := servers[addr]
+	if !ok {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			fatalf("Error listening on %v: %v", addr, err)
+		}
+		mux = http.NewServeMux()
+		servers[addr] = mux
+		go http.Serve(ln, mux)
+	}
+	mux.HandleFunc(path, h)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"logexec"
+)
+
+var (
+	kubernetesMeta bool
+	podInfoDir     string
+)
+
+// podFields maps the fields added for -kubernetes to the environment
+// variables and downward API volume files they are read from.
+var podFields = []struct {
+	field, env, file string
+}{
+	{"k8s_pod", "POD_NAME", "name"},
+	{"k8s_namespace", "POD_NAMESPACE", "namespace"},
+	{"k8s_node", "NODE_NAME", "nodename"},
+}
+
+func init() {
+	flag.BoolVar(&kubernetesMeta, "kubernetes", false,
+		"Append the pod name, namespace and node from the downward API variables POD_NAME, POD_NAMESPACE and NODE_NAME to every line")
+	flag.StringVar(&podInfoDir, "podinfo", "",
+		"downward API volume holding name, namespace, nodename and labels files to add to every line; implies -kubernetes")
+}
+
+// parsePodLabels parses the labels file of a downward API volume, which
+// holds one key="value" line per label with the value quoted as in Go.
+func parsePodLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for i, line := range strings.Split(s, "\n") {
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("line %d: must be key=\"value\"", i+1)
+		}
+		v, err := strconv.Unquote(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value %s", i+1, kv[1])
+		}
+		labels[kv[0]] = v
+	}
+	return labels, nil
+}
+
+// enrichKubernetes adds the pod fields to the processors when asked to.
+// Values in the environment take precedence over the volume files. Missing
+// values are logged but do not stop the command.
+func enrichKubernetes() {
+	if !kubernetesMeta && podInfoDir == "" {
+		return
+	}
+	for _, f := range podFields {
+		v := os.Getenv(f.env)
+		if v == "" && podInfoDir != "" {
+			b, _ := ioutil.ReadFile(filepath.Join(podInfoDir, f.file))
+			v = strings.TrimSpace(string(b))
+		}
+		if v == "" {
+			warnf("Error finding %s: %s is not set", f.field, f.env)
+			continue
+		}
+		processors = append(processors, logexec.Enrich(f.field, fieldValue(v)))
+	}
+	if podInfoDir == "" {
+		return
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(podInfoDir, "labels"))
+	if err != nil {
+		warnf("Error reading pod labels: %v", err)
+		return
+	}
+	labels, err := parsePodLabels(string(b))
+	if err != nil {
+		warnf("Error reading pod labels: %v", err)
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		processors = append(processors, logexec.Enrich("k8s_label_"+k, fieldValue(labels[k])))
+	}
+}
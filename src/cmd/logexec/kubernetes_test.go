@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePodLabels(t *testing.T) {
+	tests := []struct {
+		labels string
+		want   map[string]string
+		ok     bool
+	}{
+		{"app=\"web\"\npod-template-hash=\"5d8f7c\"\n", map[string]string{"app": "web", "pod-template-hash": "5d8f7c"}, true},
+		{"app.kubernetes.io/name=\"my \\\"app\\\"\"", map[string]string{"app.kubernetes.io/name": `my "app"`}, true},
+		{"", map[string]string{}, true},
+		{"app=web", nil, false},
+		{"=\"web\"", nil, false},
+		{"app", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parsePodLabels(tt.labels)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Error on %v, got %v, %v", tt.labels, got, err)
+		}
+	}
+}
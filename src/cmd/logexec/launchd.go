@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// launchdLabel and launchdArgs are the label and logexec arguments
+	// given to install-launchd.
+	launchdLabel string
+	launchdArgs  []string
+)
+
+// writeLaunchdPlist writes a launchd job that runs program with args under
+// label, keeping it alive. A clean exit of the command, and so of logexec,
+// is not restarted, and launchd waits long enough after SIGTERM for the
+// output to be drained.
+func writeLaunchdPlist(w io.Writer, label, program string, args []string) error {
+	esc := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", esc(label))
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, a := range append([]string{program}, args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", esc(a))
+	}
+	b.WriteString("\t</array>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	fmt.Fprintf(&b, "\t<key>ExitTimeOut</key>\n\t<integer>%d</integer>\n", int((drainTimeout+10*time.Second)/time.Second))
+	b.WriteString("</dict>\n</plist>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// installLaunchd writes the launchd job for logexec install-launchd, as a
+// daemon when run as root and as an agent of the user otherwise, and
+// returns the exit status.
+func installLaunchd(label string, args []string) int {
+	if flag.NArg() == 0 && len(configCommand) == 0 && len(runSpecs) == 0 && len(stageSpecs) == 0 {
+		fatalf("No command provided")
+	}
+	program, err := os.Executable()
+	if err != nil {
+		fatalf("Error finding the logexec executable: %v", err)
+	}
+	dir, domain := "/Library/LaunchDaemons", "system"
+	if os.Geteuid() != 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fatalf("Error finding the home directory: %v", err)
+		}
+		dir, domain = filepath.Join(home, "Library", "LaunchAgents"), fmt.Sprintf("gui/%d", os.Getuid())
+	}
+	path := filepath.Join(dir, label+".plist")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fatalf("Error creating %s: %v", dir, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		fatalf("Error writing launchd job: %v", err)
+	}
+	err = writeLaunchdPlist(f, label, program, args)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fatalf("Error writing launchd job: %v", err)
+	}
+	fmt.Printf("Wrote %s, load it with: launchctl bootstrap %s %s\n", path, domain, path)
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteLaunchdPlist(t *testing.T) {
+	var b strings.Builder
+	err := writeLaunchdPlist(&b, "com.example.agent", "/usr/local/bin/logexec",
+		[]string{"-tag", "agent", "--", "/usr/local/bin/agent", "a&b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plist struct {
+		Keys    []string `xml:"dict>key"`
+		Strings []string `xml:"dict>string"`
+		Args    []string `xml:"dict>array>string"`
+	}
+	if err := xml.Unmarshal([]byte(b.String()), &plist); err != nil {
+		t.Fatalf("Error on parsing the plist, got %v", err)
+	}
+	want := "/usr/local/bin/logexec -tag agent -- /usr/local/bin/agent a&b"
+	if got := strings.Join(plist.Args, " "); got != want {
+		t.Errorf("Error on ProgramArguments, got %v", got)
+	}
+	if strings.Join(plist.Keys, ",") != "Label,ProgramArguments,RunAtLoad,KeepAlive,ExitTimeOut" ||
+		len(plist.Strings) != 1 || plist.Strings[0] != "com.example.agent" {
+		t.Errorf("Error on the plist, got %+v", plist)
+	}
+	if !strings.Contains(b.String(), "<key>SuccessfulExit</key>\n\t\t<false/>") {
+		t.Errorf("Error on KeepAlive, got %s", b.String())
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+var (
+	lockFile string
+	lockWait bool
+
+	// lockFd keeps the lock file open, and so locked, until logexec exits.
+	lockFd *os.File
+)
+
+func init() {
+	flag.StringVar(&lockFile, "lockfile", "",
+		"lock file ensuring only one instance runs at a time")
+	flag.BoolVar(&lockWait, "lockwait", false,
+		"Wait for the lock file instead of exiting when it is held")
+}
+
+// acquireLock takes an exclusive lock on lockFile for the lifetime of
+// logexec. If another instance holds it, logexec either exits or waits,
+// depending on -lockwait, and logs the decision.
+func acquireLock() {
+	if lockFile == "" {
+		return
+	}
+
+	f, err := os.OpenFile(lockFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		fatalf("Error opening lock file: %v", err)
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		if !lockWait {
+			warnf("Lock %v is held by another instance, exiting", lockFile)
+			os.Exit(1)
+		}
+		warnf("Lock %v is held by another instance, waiting", lockFile)
+		start := time.Now()
+		for {
+			err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+			if err != syscall.EINTR {
+				break
+			}
+		}
+		if err == nil {
+			fmt.Fprintf(stdoutLog, "Acquired lock %v after %v", lockFile,
+				time.Since(start).Round(time.Second))
+		}
+	}
+	if err != nil {
+		fatalf("Error locking %v: %v", lockFile, err)
+	}
+
+	f.Truncate(0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	lockFd = f
+}
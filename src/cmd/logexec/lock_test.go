@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// runTestLock takes the lock file at path as logexec does, with -lockwait
+// if LOGEXEC_TEST_LOCKWAIT is set, logging to the socket at
+// LOGEXEC_TEST_SYSLOG.
+func runTestLock(path string) int {
+	lockFile = path
+	lockWait = os.Getenv("LOGEXEC_TEST_LOCKWAIT") != ""
+	var err error
+	if stdoutLog, err = syslog.Dial("unixgram", os.Getenv("LOGEXEC_TEST_SYSLOG"), syslog.LOG_INFO, "test"); err != nil {
+		return 2
+	}
+	acquireLock()
+	return 0
+}
+
+func TestAcquireLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock, next, done := listenSyslog(t)
+	defer done()
+	path := filepath.Join(dir, "lock")
+
+	tests := []struct {
+		// held is how long the lock is held by another instance.
+		held   time.Duration
+		wait   bool
+		status int
+		msg    string
+	}{
+		{0, false, 0, ""},
+		{0, true, 0, ""},
+		{time.Hour, false, 1, ""},
+		{300 * time.Millisecond, true, 0, "Acquired lock " + path},
+	}
+	for _, tt := range tests {
+		release := func() {}
+		if tt.held > 0 {
+			f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+				t.Fatal(err)
+			}
+			timer := time.AfterFunc(tt.held, func() { f.Close() })
+			release = func() {
+				if timer.Stop() {
+					f.Close()
+				}
+			}
+		}
+
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), "LOGEXEC_TEST_LOCK="+path, "LOGEXEC_TEST_SYSLOG="+sock)
+		if tt.wait {
+			cmd.Env = append(cmd.Env, "LOGEXEC_TEST_LOCKWAIT=1")
+		}
+		err := cmd.Run()
+		release()
+		status := 0
+		if ee, ok := err.(*exec.ExitError); ok {
+			status = ee.ExitCode()
+		}
+		b, _ := ioutil.ReadFile(path)
+		pid := strings.TrimSpace(string(b))
+		ok := status == tt.status
+		if tt.status == 0 {
+			// The instance that took the lock writes its pid to the file.
+			ok = ok && pid == strconv.Itoa(cmd.Process.Pid)
+		}
+		msg := ""
+		if tt.msg != "" {
+			msg = next()
+			ok = ok && strings.Contains(msg, tt.msg)
+		}
+		if !ok {
+			t.Errorf("Error on held %v wait %v, got %v, %q, %q", tt.held, tt.wait, status, pid, msg)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"syscall"
+	"time"
+)
+
+const (
+	retryBackoff    = 10 * time.Millisecond
+	maxRetryBackoff = time.Second
+)
+
+// logErrorPolicy decides what happens when output cannot be written to
+// syslog.
+type logErrorPolicy int
+
+const (
+	logErrorKill logErrorPolicy = iota
+	logErrorContinue
+	logErrorStopLogging
+)
+
+var logErrorNames = map[string]logErrorPolicy{
+	"kill":         logErrorKill,
+	"continue":     logErrorContinue,
+	"stop-logging": logErrorStopLogging,
+}
+
+var errInvalidLogErrorPolicy = errors.New("invalid log error policy, must be kill, continue or stop-logging")
+
+func (p logErrorPolicy) String() string {
+	for name, v := range logErrorNames {
+		if v == p {
+			return name
+		}
+	}
+	return ""
+}
+
+func (p *logErrorPolicy) Set(s string) error {
+	v, ok := logErrorNames[s]
+	if !ok {
+		return errInvalidLogErrorPolicy
+	}
+	*p = v
+	return nil
+}
+
+var (
+	onLogError = logErrorKill
+	logRetries = 5
+)
+
+func init() {
+	flag.Var(&onLogError, "on-log-error",
+		"what to do when output cannot be logged: kill the command, continue, or stop-logging")
+	flag.IntVar(&logRetries, "log-retries", logRetries,
+		"times to retry a line after a temporary error before applying -on-log-error")
+}
+
+// temporary reports whether err is likely to go away if the write is
+// retried, such as a full socket buffer.
+func temporary(err error) bool {
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOBUFS) {
+		return true
+	}
+	var t interface {
+		Temporary() bool
+	}
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// writeRetry writes b to w, retrying temporary errors up to logRetries
+// times with exponential backoff.
+func writeRetry(w io.Writer, b []byte) error {
+	backoff := retryBackoff
+	for i := 0; ; i++ {
+		_, err := w.Write(b)
+		if err == nil || i >= logRetries || !temporary(err) {
+			return err
+		}
+		debugf("Temporary error writing to syslog, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EAGAIN, true},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.ENOBUFS)}, true},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{errors.New("not temporary"), false},
+	}
+	for _, tt := range tests {
+		if got := temporary(tt.err); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.err, got)
+		}
+	}
+}
+
+// flakyWriter fails with EAGAIN until it has been called fails times.
+type flakyWriter struct {
+	fails, calls int
+}
+
+func (w *flakyWriter) Write(b []byte) (int, error) {
+	w.calls++
+	if w.calls <= w.fails {
+		return 0, syscall.EAGAIN
+	}
+	return len(b), nil
+}
+
+func TestWriteRetry(t *testing.T) {
+	tests := []struct {
+		fails   int
+		wantErr bool
+	}{
+		{0, false},
+		{2, false},
+		{logRetries, false},
+		{logRetries + 1, true},
+	}
+	for _, tt := range tests {
+		w := &flakyWriter{fails: tt.fails}
+		if err := writeRetry(w, []byte("line")); (err != nil) != tt.wantErr {
+			t.Errorf("Error on %d failures, got %v", tt.fails, err)
+		}
+	}
+}
@@ -0,0 +1,902 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"logexec"
+)
+
+var (
+	stdoutLog, stderrLog *syslog.Writer
+
+	facility    = logFacility(syslog.LOG_LOCAL0)
+	stdoutLevel = logLevel(syslog.LOG_INFO)
+	stderrLevel = logLevel(syslog.LOG_WARNING)
+	ignoreSig   = false
+	initMode    = false
+	exitOnFirst = false
+	tag         string
+	stdoutTag   string
+	stderrTag   string
+	runSpecs    runList
+	stageSpecs  runList
+
+	stdoutFacility, stderrFacility streamFacility
+
+	maxLogLine = flag.Int("maxline", 8*1024,
+		"maximum amount of text to log in a line")
+)
+
+func init() {
+	flag.Var(&facility, "facility", "logging facility")
+	flag.Var(&stdoutFacility, "stdoutFacility", "logging facility for stdout, if different from -facility")
+	flag.Var(&stderrFacility, "stderrFacility", "logging facility for stderr, if different from -facility")
+	flag.Var(&stdoutLevel, "stdoutLevel", "log level for stdout")
+	flag.Var(&stderrLevel, "stderrLevel", "log level for stderr")
+	flag.BoolVar(&ignoreSig, "ignoresig", false,
+		"Do not pass any signals on to child process")
+	flag.StringVar(&tag, "tag", "logexec", "Tag for all log messages")
+	flag.StringVar(&stdoutTag, "stdoutTag", "", "Tag for stdout messages, if different from -tag")
+	flag.StringVar(&stderrTag, "stderrTag", "", "Tag for stderr messages, if different from -tag")
+	flag.BoolVar(&initMode, "init", false,
+		"Run as an init process, reaping orphaned children")
+	flag.Var(&runSpecs, "run",
+		"additional command to run as name:cmd [args], logged with tag name")
+	flag.Var(&stageSpecs, "stage",
+		"pipeline stage as name:cmd [args], piped into the next stage")
+	flag.BoolVar(&exitOnFirst, "exit-on-first", false,
+		"Stop the remaining commands when the first one exits")
+
+}
+
+// syslogPaths are the sockets UnixSyslog tries, in order.
+var syslogPaths = []string{
+	"/run/systemd/journal/syslog",
+	"/dev/log",
+	"/var/run/syslog",
+	"/var/run/log",
+}
+
+func UnixSyslog(priority syslog.Priority, tag string) (*syslog.Writer, error) {
+	logTypes := []string{"unixgram", "unix"}
+	for _, network := range logTypes {
+		for _, path := range syslogPaths {
+			slog, err := syslog.Dial(network, path, priority, tag)
+			if err != nil {
+				debugf("Connecting to syslog at %s %s: %v", network, path, err)
+				continue
+			} else {
+				debugf("Connected to syslog at %s %s for %s", network, path, tag)
+				return slog, nil
+			}
+		}
+	}
+	return nil, errors.New("Unix syslog delivery error")
+}
+
+// streamStats counts what has been logged from one of the output streams,
+// along with the lines dropped by its queue.
+type streamStats struct {
+	logexec.StreamStats
+	dropped int64
+}
+
+// outputStream is a stream of the main command's output along with its
+// statistics for one run and the totals of the previous ones.
+type outputStream struct {
+	name        string
+	stats, past *streamStats
+}
+
+// newOutputStreams returns stdout, stderr and the -fd streams with the
+// statistics of a new run, in the order streamTotals returns their totals
+// in.
+func newOutputStreams() []outputStream {
+	streams := []outputStream{
+		{"stdout", &streamStats{}, &pastStats[0]},
+		{"stderr", &streamStats{}, &pastStats[1]},
+	}
+	for _, s := range fdStreams {
+		streams = append(streams, outputStream{s.name(), &streamStats{}, s.past})
+	}
+	return streams
+}
+
+// storeMax atomically raises *addr to v if v is larger.
+func storeMax(addr *int64, v int64) {
+	for {
+		old := atomic.LoadInt64(addr)
+		if v <= old || atomic.CompareAndSwapInt64(addr, old, v) {
+			return
+		}
+	}
+}
+
+// logPipe logs the lines read from r to w, and reports how it ended on
+// logErr.
+func (run *runState) logPipe(w io.Writer, r io.Reader, stats *streamStats) {
+	defer run.loggers.Done()
+	q := newLineQueue(w, stats)
+	defer q.Close()
+	err := readLines(q, r, stats)
+	if err == nil {
+		err = io.EOF
+	}
+	run.logErr <- err
+}
+
+// readLines writes the lines read from r to w, as fast as -throttle
+// allows, and counts them in stats.
+func readLines(w io.Writer, r io.Reader, stats *streamStats) error {
+	lw := newStreamReader(w, logexec.LineOptions{
+		MaxLine: *maxLogLine,
+		Stats:   &stats.StreamStats,
+	})
+	_, err := lw.ReadFrom(throttled(r))
+	return err
+}
+
+// child is a command started by logexec along with the syslog writers its
+// output is sent to.
+type child struct {
+	name string
+	cmd  *exec.Cmd
+	// path is the command run, which cmd.Path is not when it is started
+	// through a trampoline.
+	path           string
+	stdout, stderr *spoolWriter
+	streams        []*spoolWriter
+	stderrTail     *lastLines
+	// runner runs the command and logs its stdout and stderr, until
+	// stopOutput closes them.
+	runner     *logexec.Runner
+	stopOutput context.CancelFunc
+	// pipes are the read ends of the -fd pipes, which logs log once the
+	// command has started.
+	pipes []io.Closer
+	logs  []func()
+	start time.Time
+	// sent holds the signals logexec sent the command, and stopped whether
+	// it told it to stop.
+	sent    map[syscall.Signal]bool
+	stopped bool
+	exited  bool
+	status  int
+	signal  syscall.Signal
+	core    bool
+	rusage  *syscall.Rusage
+}
+
+// childExit reports that a child has exited.
+type childExit struct {
+	child  *child
+	err    error
+	rusage *syscall.Rusage
+}
+
+// stdoutPriority and stderrPriority return the syslog priorities of the
+// streams, from their levels and facilities.
+func stdoutPriority() syslog.Priority {
+	return syslog.Priority(stdoutLevel) | stdoutFacility.priority()
+}
+
+func stderrPriority() syslog.Priority {
+	return syslog.Priority(stderrLevel) | stderrFacility.priority()
+}
+
+func openLogs(stdoutTag, stderrTag string) (*syslog.Writer, *syslog.Writer, error) {
+	stdout, err := UnixSyslog(stdoutPriority(), stdoutTag)
+	if err != nil {
+		return nil, nil, fmt.Errorf("initializing stdout syslog: %v", err)
+	}
+
+	stderr, err := UnixSyslog(stderrPriority(), stderrTag)
+	if err != nil {
+		stdout.Close()
+		return nil, nil, fmt.Errorf("initializing stderr syslog: %v", err)
+	}
+	return stdout, stderr, nil
+}
+
+// openOwnLogs opens the syslog writers of logexec's own messages, exiting
+// if it cannot.
+func openOwnLogs() {
+	var err error
+	stdoutLog, stderrLog, err = openLogs(runSpec{name: tag, stdoutTag: stdoutTag, stderrTag: stderrTag}.tags())
+	if err == nil {
+		err = openSelfLog()
+	}
+	if err != nil {
+		fatalf("Error %v", err)
+	}
+}
+
+// startCmd starts the command described by spec through a logexec.Runner.
+// Its stdin is connected to stdin, or set up according to -stdin if nil.
+// Its stdout is sent to stdout when given, and logged otherwise. If it
+// fails, whatever was set up for the command is released.
+func (run *runState) startCmd(spec runSpec, stdin, stdout *os.File) (c *child, err error) {
+	c = &child{name: spec.name}
+	outTag, errTag := spec.tags()
+	outLog, errLog, err := openLogs(outTag, errTag)
+	if err != nil {
+		return nil, err
+	}
+	c.stdout, err = newSpoolWriter(outLog, outTag, "stdout")
+	if err == nil {
+		c.stderr, err = newSpoolWriter(errLog, errTag, "stderr")
+	}
+	if err != nil {
+		outLog.Close()
+		errLog.Close()
+		return nil, err
+	}
+	c.stderrTail = newLastLines(newRecordWriter(c.stderr, errTag, "stderr", stderrPriority()), crashLines)
+	outQ := newLineQueue(newRecordWriter(c.stdout, outTag, "stdout", stdoutPriority()), run.stats("stdout"))
+	errQ := newLineQueue(c.stderrTail, run.stats("stderr"))
+	// The write ends are closed first, so that the loggers already
+	// started see the end of their pipes.
+	var childEnds []*os.File
+	defer func() {
+		for _, f := range childEnds {
+			f.Close()
+		}
+		if err != nil {
+			outQ.Close()
+			errQ.Close()
+			c.close()
+		}
+	}()
+
+	var ctx context.Context
+	ctx, c.stopOutput = context.WithCancel(context.Background())
+	c.runner = &logexec.Runner{
+		Args:    spec.args,
+		Stdout:  outQ,
+		Stderr:  errQ,
+		MaxLine: *maxLogLine,
+		Copy: func(stream string, w io.Writer, r io.Reader, _ logexec.LineOptions) error {
+			return readLines(w, r, run.stats(stream))
+		},
+		StartCommand: func(cmd *exec.Cmd) error {
+			ends, err := run.setupCmd(c, spec, cmd, stdin, stdout)
+			childEnds = append(childEnds, ends...)
+			return err
+		},
+		WaitCommand: func(cmd *exec.Cmd) error {
+			rusage, err := waitChild(cmd)
+			run.exits <- childExit{child: c, err: err, rusage: rusage}
+			return err
+		},
+		// Errors writing the output are reported as they happen, so that
+		// the run is cancelled rather than the rest of it discarded.
+		OnSinkError: func(stream string, err error) {
+			run.logErr <- err
+		},
+	}
+	if stdin != nil {
+		c.runner.Stdin = stdin
+	}
+	if err := c.runner.Start(ctx); err != nil {
+		c.stopOutput()
+		return c, err
+	}
+	c.start = time.Now()
+	run.loggers.Add(1)
+	go func() {
+		defer run.loggers.Done()
+		// Reading a pipe does not fail otherwise, so Wait only returns
+		// the errors already reported by OnSinkError.
+		c.runner.Wait()
+		outQ.Close()
+		errQ.Close()
+	}()
+	for _, log := range c.logs {
+		run.loggers.Add(1)
+		go log()
+	}
+	c.logs = nil
+	logStart(c)
+	if startBanner {
+		logBanner(c)
+	}
+	return c, nil
+}
+
+// setupCmd sets up cmd, the command of c described by spec, and starts it.
+// It is the StartCommand of the Runner of c. The write ends of the pipes it
+// creates are returned to be closed once cmd has started.
+func (run *runState) setupCmd(c *child, spec runSpec, cmd *exec.Cmd, stdin, stdout *os.File) ([]*os.File, error) {
+	cmdName := spec.args[0]
+	t := childTrampoline()
+	if stdin == nil {
+		setupStdin(cmd, &t)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	setupCommand(cmd)
+	if err := setupChroot(cmd, cmdName); err != nil {
+		return nil, fmt.Errorf("setting up chroot: %v", err)
+	}
+	if err := setupCgroup(cmd); err != nil {
+		return nil, fmt.Errorf("setting up cgroup: %v", err)
+	}
+	passDevlog(cmd)
+	c.path = cmd.Path
+	var childEnds []*os.File
+	if spec.main {
+		t.ListenPID = passListenFds(cmd)
+		ends, err := run.setupFdStreams(cmd, c)
+		childEnds = append(childEnds, ends...)
+		if err != nil {
+			return childEnds, err
+		}
+	}
+	trampolineStarted, err := setupTrampoline(cmd, t)
+	if err != nil {
+		return childEnds, err
+	}
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+
+	c.cmd = cmd
+	err = startChild(cmd)
+	if terr := trampolineStarted(); err == nil {
+		err = terr
+	}
+	return childEnds, err
+}
+
+// close closes the read ends of the -fd pipes of a child and its writers,
+// once its output has been logged or it could not be started.
+func (c *child) close() {
+	for _, p := range c.pipes {
+		p.Close()
+	}
+	c.stdout.Close()
+	c.stderr.Close()
+	for _, sw := range c.streams {
+		sw.Close()
+	}
+}
+
+// send sends sig to the command, recording that logexec did, and that it
+// told the command to stop if stop is set, so that its exit is not reported
+// as a failure to be notified of.
+func (c *child) send(sig os.Signal, stop bool) error {
+	if s, ok := sig.(syscall.Signal); ok {
+		if c.sent == nil {
+			c.sent = map[syscall.Signal]bool{}
+		}
+		c.sent[s] = true
+	}
+	c.stopped = c.stopped || stop
+	return c.cmd.Process.Signal(sig)
+}
+
+func killChildren(children []*child) {
+	for _, c := range children {
+		c.send(syscall.SIGKILL, true)
+	}
+}
+
+// logExit logs a command's non-zero exit to its stderr writer.
+func logExit(c *child, err error, status int) {
+	if ws, ok := logexec.WaitStatus(err); ok && ws.Signaled() {
+		core := ""
+		if ws.CoreDump() {
+			core = " (core dumped)"
+		}
+		fmt.Fprintf(c.stderr, "Command killed by signal %v%s, exit status %v%s",
+			signalName(ws.Signal()), core, status, runIDField())
+		return
+	}
+	fmt.Fprintf(c.stderr, "Command return non-zero exit status: %v%s", status, runIDField())
+}
+
+// runState holds what the commands of one run share: the context that
+// stops them, the goroutines logging their output and the statistics of
+// the run.
+type runState struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// loggers counts the goroutines logging output, which report errors
+	// on logErr.
+	loggers sync.WaitGroup
+	logErr  chan error
+	// exits receives the exits of the commands, as they are waited for.
+	exits chan childExit
+
+	start   time.Time
+	streams []outputStream
+
+	// reportedDrops holds the drop counts of each stream already logged.
+	reportedDrops map[string]int64
+}
+
+// errFirstExited cancels a run with -exit-on-first once one of its commands
+// has exited.
+var errFirstExited = errors.New("first command exited")
+
+// newRunState returns the state of a run under ctx. The run is cancelled on
+// its own rather than with ctx, as logexec being told to stop is passed on
+// to the commands as the signal it was told with.
+func newRunState(ctx context.Context) *runState {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	return &runState{
+		ctx:           ctx,
+		cancel:        cancel,
+		logErr:        make(chan error),
+		start:         time.Now(),
+		streams:       newOutputStreams(),
+		reportedDrops: map[string]int64{},
+	}
+}
+
+// stats returns the statistics of the output stream called name in the
+// run.
+func (run *runState) stats(name string) *streamStats {
+	for _, s := range run.streams {
+		if s.name == name {
+			return s.stats
+		}
+	}
+	return nil
+}
+
+// abort kills the commands of a run that could not be started, and waits
+// for them and for the loggers of their output.
+func (run *runState) abort(children []*child) {
+	killChildren(children)
+	closePipes(children)
+	done := make(chan struct{})
+	go func() {
+		run.loggers.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-run.logErr:
+		case <-done:
+			for _, c := range children {
+				c.close()
+			}
+			return
+		}
+	}
+}
+
+// runOnce runs the pre-exec hook, the commands and the post-exec hook, and
+// returns the exit status. Signals caught on sigs are handled and passed on
+// to the commands; ctx is done once one of them has told logexec to stop.
+// The commands are killed if the run is cancelled, by the -watchdog or a
+// failure to log their output, and told to stop with SIGTERM when one
+// exits with -exit-on-first.
+func runOnce(ctx context.Context, sigs <-chan os.Signal, specs []runSpec) int {
+	if status := runPreExec(); status != 0 {
+		return status
+	}
+	run := newRunState(ctx)
+	startStats(run)
+	defer func() { run.cancel(nil) }()
+	tails, err := startTails()
+	var fifos []*fifoReader
+	var devlogs *devlogReader
+	if err == nil {
+		fifos, err = startFifos()
+	}
+	if err == nil {
+		devlogs, err = startDevlog()
+	}
+	if err != nil {
+		stopTails(tails)
+		stopFifos(fifos)
+		errorf("Error %v", err)
+		return 1
+	}
+	var children []*child
+	run.exits = make(chan childExit, len(stageSpecs)+len(specs))
+	if len(stageSpecs) > 0 {
+		children, err = run.startPipeline(stageSpecs)
+	}
+	for _, spec := range specs {
+		if err != nil {
+			break
+		}
+		var c *child
+		c, err = run.startCmd(spec, nil, nil)
+		if err == nil {
+			children = append(children, c)
+		}
+	}
+	if err != nil {
+		stopTails(tails)
+		stopFifos(fifos)
+		stopDevlog(devlogs)
+		run.abort(children)
+		removeCgroup()
+		errorf("Error starting command: %v", err)
+		return logexec.ExitStatus(err)
+	}
+
+	if initMode {
+		defer startReaper()()
+	}
+	// Signal with a channel when the loggers have completed
+	doneChan := make(chan bool)
+	go func() {
+		run.loggers.Wait()
+		close(doneChan)
+	}()
+
+	watchdogC := startWatchdog()
+	heartbeatC := startHeartbeat()
+	dropC := startDropReport()
+	queueReportC := startQueueReport()
+	sinkFlushC := startSinkFlush()
+	sampleC := startSampler()
+	treeC := startTreeTracker()
+	checkpointC := startCheckpoints()
+	stateC := startStateSave()
+	var drainC <-chan time.Time
+	cancelC := run.ctx.Done()
+	running := len(children)
+	atomic.StoreInt64(&childrenRunning, int64(running))
+	estatus := 0
+	logFailed := false
+	for !(running == 0 && doneChan == nil) {
+		select {
+		case <-cancelC:
+			if running == 0 {
+				cancelC = nil
+				continue
+			}
+			if !errors.Is(context.Cause(run.ctx), errFirstExited) {
+				killChildren(children)
+				cancelC = nil
+				continue
+			}
+			for _, c := range children {
+				if !c.exited {
+					c.send(syscall.SIGTERM, true)
+				}
+			}
+			// Telling the others to stop does not end the run, so it can
+			// still be cancelled if they do not.
+			run.ctx, run.cancel = context.WithCancelCause(context.WithoutCancel(run.ctx))
+			cancelC = run.ctx.Done()
+		case sig := <-sigs:
+			if dumpSignal.is(sig) {
+				logSignal(sig, "dump", nil, nil)
+				dumpState(children)
+				continue
+			}
+			if reopenSignal.is(sig) {
+				logSignal(sig, "reopen", nil, nil)
+				reopenLogs(children)
+				continue
+			}
+			if reloading(sig) {
+				logSignal(sig, "reload", nil, nil)
+				reloadConfig()
+				continue
+			}
+			if ignoredSignal(sig) {
+				logSignal(sig, "suppressed", nil, nil)
+				continue
+			}
+			out := signalMapping.translate(sig)
+			logSignal(sig, "forwarded", out, children)
+			stop := sig == syscall.SIGINT || sig == syscall.SIGTERM
+			for _, c := range children {
+				c.send(out, stop)
+			}
+		case <-doneChan:
+			doneChan = nil
+		case <-drainC:
+			fmt.Fprintf(stderrLog, "Output still open %v after command exited, closing", drainTimeout)
+			closePipes(children)
+			drainC = nil
+		case <-dropC:
+			run.reportDrops()
+		case <-queueReportC:
+			reportQueue()
+		case <-sinkFlushC:
+			flushSinks()
+		case <-sampleC:
+			logSamples(children)
+		case <-treeC:
+			trackTree(children)
+		case <-checkpointC:
+			writeCheckpoints()
+		case <-stateC:
+			writeState()
+		case <-heartbeatC:
+			fmt.Fprint(stdoutLog, run.heartbeatMessage(children))
+		case <-watchdogC:
+			if idle, expired := silentFor(); expired {
+				fmt.Fprintf(stderrLog, "No output for %v, killing command", idle.Round(time.Second))
+				run.cancel(nil)
+				watchdogC = nil
+			}
+		case exit := <-run.exits:
+			running--
+			atomic.StoreInt64(&childrenRunning, int64(running))
+			status := logexec.ExitStatus(exit.err)
+			exit.child.exited = true
+			exit.child.status = status
+			exit.child.rusage = exit.rusage
+			if ws, ok := logexec.WaitStatus(exit.err); ok && ws.Signaled() {
+				exit.child.signal = ws.Signal()
+				exit.child.core = ws.CoreDump()
+			}
+			if status != 0 {
+				logExit(exit.child, exit.err, status)
+			}
+			logEnd(exit.child)
+			notifyExit(exit.child)
+			if estatus == 0 && (!exitOnFirst || running == len(children)-1) {
+				estatus = status
+			}
+			if exitOnFirst && running == len(children)-1 && running > 0 {
+				run.cancel(errFirstExited)
+			}
+			if running == 0 {
+				removeCgroup()
+				if len(stageSpecs) > 0 {
+					estatus = pipelineStatus(children)
+				}
+				drainC = startDrain()
+			}
+		case err := <-run.logErr:
+			if err != nil && err != io.EOF && !errors.Is(err, os.ErrClosed) &&
+				!strings.Contains(err.Error(), "bad file descriptor") {
+				errorf("Error logging command output: %v", err)
+				logFailed = true
+				run.cancel(err)
+			}
+		}
+	}
+
+	stopTails(tails)
+	stopFifos(fifos)
+	stopDevlog(devlogs)
+	closePipes(children)
+	writeCheckpoints()
+	for _, c := range children {
+		if crashLines > 0 && crashed(c) {
+			logCrashReport(c)
+		}
+		c.stdout.Close()
+		c.stderr.Close()
+		for _, sw := range c.streams {
+			sw.Close()
+		}
+	}
+	if logFailed {
+		estatus = 1
+	}
+	run.finish(estatus, children)
+	return estatus
+}
+
+// finish records the outcome of the run and runs the post-exec hook.
+func (run *runState) finish(status int, children []*child) {
+	run.logSummary(children)
+	run.reportDrops()
+	flushStatsd()
+	flushSpools()
+	flushSinks()
+	writeState()
+	run.writeStatusFile(status, children)
+	runPostExec(run, status)
+	waitNotifications()
+}
+
+// subcommand returns the subcommand given as the first argument, if any,
+// and parses the flags that follow it. Anything else is the command to run,
+// as in a bare invocation of logexec, and so is a name after -- or that of
+// a program in PATH, which logexec ran before it had subcommands.
+func subcommand() string {
+	name := flag.Arg(0)
+	if n := len(os.Args) - flag.NArg(); n > 1 && os.Args[n-1] == "--" {
+		return ""
+	}
+	if _, err := exec.LookPath(name); name != "" && err == nil {
+		debugf("Running %s from PATH rather than the subcommand", name)
+		return ""
+	}
+	switch {
+	case name == "run", name == "check", name == "version", name == "bench",
+		name == "spool" && flag.NArg() > 1,
+		name == "check-config" && flag.NArg() > 1,
+		name == "completion" && flag.NArg() > 1:
+		flag.CommandLine.Parse(flag.Args()[1:])
+		return name
+	case name == "install-launchd" && flag.NArg() > 2:
+		// The job runs logexec with the arguments given around the
+		// subcommand and label.
+		launchdLabel = flag.Arg(1)
+		launchdArgs = append(append([]string(nil), os.Args[1:len(os.Args)-flag.NArg()]...), flag.Args()[2:]...)
+		flag.CommandLine.Parse(flag.Args()[2:])
+		return name
+	}
+	return ""
+}
+
+func main() {
+	if t, ok := os.LookupEnv(trampolineEnv); ok {
+		runTrampoline(t)
+	}
+	flag.Parse()
+	sub := subcommand()
+	if err := loadEnv(); err != nil {
+		fatalf("Error in environment: %v", err)
+	}
+	if configFile != "" {
+		if err := loadConfig(configFile); err != nil {
+			fatalf("Error loading configuration: %v", err)
+		}
+	}
+	if showVersion {
+		sub = "version"
+	}
+	switch sub {
+	case "version":
+		fmt.Println(versionString())
+		return
+	case "spool":
+		os.Exit(spoolCommand(flag.Args()))
+	case "check-config":
+		os.Exit(checkConfig(flag.Arg(0)))
+	case "install-launchd":
+		os.Exit(installLaunchd(launchdLabel, launchdArgs))
+	case "completion":
+		if err := writeCompletion(os.Stdout, flag.Arg(0)); err != nil {
+			fatalf("Error writing completion: %v", err)
+		}
+		return
+	case "check":
+		checkOnly = true
+	case "bench":
+		if flag.NArg() > 0 {
+			fatalf("The bench subcommand takes no command")
+		}
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		args = configCommand
+	}
+	var cmd string
+	switch {
+	case len(args) > 0:
+		cmd = args[0]
+	case len(runSpecs) > 0:
+		cmd = runSpecs[0].args[0]
+	case len(stageSpecs) > 0:
+		cmd = stageSpecs[0].args[0]
+	}
+	for _, t := range []*string{&tag, &stdoutTag, &stderrTag, &selfTag} {
+		var err error
+		if *t, err = expandTag(*t, cmd); err != nil {
+			fatalf("Error expanding tag: %v", err)
+		}
+	}
+	specs := []runSpec(runSpecs)
+	if len(args) > 0 {
+		first := runSpec{name: tag, args: args, stdoutTag: stdoutTag, stderrTag: stderrTag}
+		specs = append([]runSpec{first}, specs...)
+	}
+	pipeMode := isPipeMode(args)
+	if pipeMode && len(specs) > 1 || pipeMode && len(stageSpecs) > 0 {
+		fatalf("Pipe mode cannot be combined with other commands")
+	}
+	if len(stageSpecs) > 0 && len(specs) > 0 {
+		fatalf("Pipeline stages cannot be combined with other commands")
+	}
+	if len(specs) == 0 && len(stageSpecs) == 0 && sub != "bench" {
+		fatalf("No command provided")
+	}
+	if queueSize < 1 {
+		fatalf("Queue size must be at least 1")
+	}
+	if *maxLogLine < logexec.MinMaxLine {
+		fatalf("Maximum line length must be at least %d", logexec.MinMaxLine)
+	}
+	if sub == "bench" {
+		openOwnLogs()
+		openSinks()
+		status := runBench()
+		closeSinks()
+		os.Exit(status)
+	}
+	if dryRun {
+		printConfig(os.Stdout, specs)
+		return
+	}
+	if checkOnly {
+		if pipeMode {
+			specs = nil
+		}
+		os.Exit(runCheck(specs))
+	}
+	if err := loadState(); err != nil {
+		fatalf("Error loading state file: %v", err)
+	}
+	setupRunID()
+	enrichContainer()
+	enrichKubernetes()
+	if pipeMode {
+		openOwnLogs()
+		openSinks()
+		// The lines already read are logged before exiting on SIGINT or
+		// SIGTERM.
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		status, err := runPipe(ctx, os.Stdin, "stdin")
+		stop()
+		if err != nil {
+			errorf("Error %v", err)
+		}
+		closeSinks()
+		os.Exit(status)
+	}
+
+	takeListenFds()
+	if len(specs) > 0 {
+		specs[0].main = true
+	} else {
+		stageSpecs[0].main = true
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals()...)
+	if reopenSignal != 0 {
+		signal.Notify(sigs, syscall.Signal(reopenSignal))
+	}
+	if dumpSignal != 0 {
+		signal.Notify(sigs, syscall.Signal(dumpSignal))
+	}
+	if configFile != "" && reloadSignal != 0 {
+		signal.Notify(sigs, syscall.Signal(reloadSignal))
+	}
+	if initMode {
+		signal.Notify(sigchld, syscall.SIGCHLD)
+		if err := setSubreaper(); err != nil {
+			warnf("Error becoming child subreaper: %v", err)
+		}
+	}
+
+	openOwnLogs()
+	acquireLock()
+	startMetrics()
+	startHealth()
+	startPprof()
+	startStatsd()
+	openSinks()
+
+	ctx, stop := stopContext()
+	defer stop()
+	if every > 0 {
+		runEvery(ctx, sigs, specs)
+		closeSinks()
+		return
+	}
+	status := runOnce(ctx, sigs, specs)
+	closeSinks()
+	os.Exit(status)
+}
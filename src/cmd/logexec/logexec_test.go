@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"logexec"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("not an exit"), 1},
+		{&exec.Error{Name: "missing", Err: exec.ErrNotFound}, 127},
+		{&os.PathError{Op: "fork/exec", Path: "./missing", Err: syscall.ENOENT}, 127},
+		{&os.PathError{Op: "fork/exec", Path: "/etc/passwd", Err: syscall.EACCES}, 126},
+		{&os.PathError{Op: "fork/exec", Path: "./script", Err: syscall.ENOEXEC}, 126},
+		{&reapedError{status: syscall.WaitStatus(3 << 8)}, 3},
+		{&reapedError{status: syscall.WaitStatus(syscall.SIGKILL)}, 137},
+		{&reapedError{status: syscall.WaitStatus(syscall.SIGSEGV | 0x80)}, 139},
+	}
+	for _, tt := range tests {
+		if got := logexec.ExitStatus(tt.err); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.err, got)
+		}
+	}
+}
+
+func TestSubcommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// A program called bench is in PATH, and none of the other names.
+	if err := ioutil.WriteFile(filepath.Join(dir, "bench"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir)
+	defer func(args []string, fs *flag.FlagSet) {
+		os.Args, flag.CommandLine = args, fs
+		launchdLabel, launchdArgs = "", nil
+	}(os.Args, flag.CommandLine)
+
+	tests := []struct {
+		args  []string
+		want  string
+		rest  []string
+		label string
+	}{
+		{[]string{"run", "-tag", "x"}, "run", nil, ""},
+		{[]string{"-tag", "x", "check", "extra"}, "check", []string{"extra"}, ""},
+		{[]string{"version"}, "version", nil, ""},
+		{[]string{"bench"}, "", []string{"bench"}, ""},
+		{[]string{"--", "version"}, "", []string{"version"}, ""},
+		{[]string{"-tag", "x", "--", "run"}, "", []string{"run"}, ""},
+		{[]string{"spool"}, "", []string{"spool"}, ""},
+		{[]string{"spool", "list"}, "spool", []string{"list"}, ""},
+		{[]string{"check-config"}, "", []string{"check-config"}, ""},
+		{[]string{"completion", "bash"}, "completion", []string{"bash"}, ""},
+		{[]string{"install-launchd", "job"}, "", []string{"install-launchd", "job"}, ""},
+		{[]string{"-tag", "x", "install-launchd", "job", "myjob"}, "install-launchd", []string{"myjob"}, "job"},
+		{[]string{"myjob", "run"}, "", []string{"myjob", "run"}, ""},
+	}
+	for _, tt := range tests {
+		os.Args = append([]string{"logexec"}, tt.args...)
+		flag.CommandLine = flag.NewFlagSet("logexec", flag.ContinueOnError)
+		flag.CommandLine.String("tag", "", "")
+		flag.CommandLine.Parse(tt.args)
+		launchdLabel = ""
+		got := subcommand()
+		rest := strings.Join(flag.Args(), " ")
+		if got != tt.want || rest != strings.Join(tt.rest, " ") || launchdLabel != tt.label {
+			t.Errorf("Error on %q, got %q, %q, %q", tt.args, got, flag.Args(), launchdLabel)
+		}
+	}
+}
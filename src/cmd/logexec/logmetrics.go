@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var metricName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// lineMetric counts the lines matching re. If re has a group, the number
+// it matches is added up as well.
+type lineMetric struct {
+	name string
+	re   *regexp.Regexp
+
+	mu    sync.Mutex
+	count int64
+	sum   float64
+}
+
+func (m *lineMetric) extracts() bool {
+	return m.re.NumSubexp() > 0
+}
+
+// observe counts line if it matches, adding the value of the group.
+// Matching lines whose group is not a number are counted all the same.
+func (m *lineMetric) observe(line []byte) {
+	match := m.re.FindSubmatch(line)
+	if match == nil {
+		return
+	}
+	var v float64
+	if m.extracts() {
+		v, _ = strconv.ParseFloat(string(match[1]), 64)
+	}
+	m.mu.Lock()
+	m.count++
+	m.sum += v
+	m.mu.Unlock()
+}
+
+func (m *lineMetric) values() (int64, float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.count, m.sum
+}
+
+// metricList collects repeated -metric name=regexp rules.
+type metricList []*lineMetric
+
+func (l metricList) String() string {
+	var s []string
+	for _, m := range l {
+		s = append(s, m.name+"="+m.re.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func (l *metricList) Set(to string) error {
+	kv := strings.SplitN(to, "=", 2)
+	if len(kv) != 2 || !metricName.MatchString(kv[0]) {
+		return fmt.Errorf("invalid metric %q, must be name=regexp with a name of letters, digits and _", to)
+	}
+	for _, m := range *l {
+		if m.name == kv[0] {
+			return fmt.Errorf("metric %s given twice", kv[0])
+		}
+	}
+	re, err := regexp.Compile(kv[1])
+	if err != nil {
+		return err
+	}
+	*l = append(*l, &lineMetric{name: kv[0], re: re})
+	return nil
+}
+
+var lineMetrics metricList
+
+func init() {
+	flag.Var(&lineMetrics, "metric",
+		"count lines matching name=regexp, adding up the number matched by its first group if any, for -metrics-addr and -statsd-addr; may be repeated")
+}
+
+// observeMetrics passes line to every -metric rule.
+func observeMetrics(line []byte) {
+	for _, m := range lineMetrics {
+		m.observe(line)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestLineMetric(t *testing.T) {
+	var l metricList
+	for _, rule := range []string{"errors=ERROR", `latency_ms=took (\d+(\.\d+)?)ms`} {
+		if err := l.Set(rule); err != nil {
+			t.Fatalf("Error on %v, got %v", rule, err)
+		}
+	}
+	for _, line := range []string{"ERROR one", "took 12ms", "ok", "ERROR two", "took 0.5ms", "took xms"} {
+		for _, m := range l {
+			m.observe([]byte(line))
+		}
+	}
+	tests := []struct {
+		count int64
+		sum   float64
+	}{{2, 0}, {2, 12.5}}
+	for i, tt := range tests {
+		if count, sum := l[i].values(); count != tt.count || sum != tt.sum {
+			t.Errorf("Error on %v, got %v, %v", l[i].name, count, sum)
+		}
+	}
+
+	for _, rule := range []string{"errors=again", "bad name=x", "=x", "x", "bad=("} {
+		if err := l.Set(rule); err == nil {
+			t.Errorf("Error on %v, got no error", rule)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// logStart writes a begin marker for c, so every run can be audited from
+// the log even when the command itself is silent.
+func logStart(c *child) {
+	fmt.Fprintf(c.stdout, "Command started: cmdline=%q pid=%d start=%s%s",
+		strings.Join(c.cmd.Args, " "), c.cmd.Process.Pid,
+		c.start.Format(time.RFC3339), runIDField())
+}
+
+// logEnd writes an end marker for c, at the stderr level if it failed.
+func logEnd(c *child) {
+	w := c.stdout
+	if c.status != 0 {
+		w = c.stderr
+	}
+	fmt.Fprintf(w, "Command exited: cmdline=%q pid=%d start=%s duration=%v status=%d%s",
+		strings.Join(c.cmd.Args, " "), c.cmd.Process.Pid,
+		c.start.Format(time.RFC3339), time.Since(c.start).Round(time.Millisecond),
+		c.status, runIDField())
+}
+
+// logSummary writes the statistics of the run once all output is logged,
+// along with the resource usage of children.
+func (run *runState) logSummary(children []*child) {
+	var parts []string
+	for _, s := range run.streams {
+		parts = append(parts, fmt.Sprintf(
+			"%[1]s_lines=%[2]d %[1]s_bytes=%[3]d %[1]s_truncated=%[4]d %[1]s_dropped=%[5]d %[1]s_longest=%[6]d",
+			s.name, atomic.LoadInt64(&s.stats.Lines), atomic.LoadInt64(&s.stats.Bytes),
+			atomic.LoadInt64(&s.stats.Truncated), atomic.LoadInt64(&s.stats.dropped),
+			atomic.LoadInt64(&s.stats.Longest)))
+	}
+	fmt.Fprintf(stdoutLog, "Run summary: duration=%v %s %s%s",
+		time.Since(run.start).Round(time.Millisecond), strings.Join(parts, " "),
+		rusageSummary(children), runIDField())
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	metricsAddr string
+
+	// pastStats holds the totals of previous runs of stdout and stderr, so
+	// that the counters keep increasing when a new run starts its own
+	// statistics. Those of -fd streams are kept with the streams.
+	pastStats [2]streamStats
+
+	// currentRun is the run whose statistics are added to pastStats in the
+	// totals.
+	statsMu    sync.Mutex
+	currentRun *runState
+
+	runs            int64
+	sinkErrors      int64
+	reconnects      int64
+	failingWriters  int64
+	childrenRunning int64
+	childStart      int64
+)
+
+func init() {
+	flag.StringVar(&metricsAddr, "metrics-addr", "",
+		"address to serve Prometheus metrics on at /metrics, e.g. :9090")
+}
+
+// startMetrics serves the metrics endpoint if -metrics-addr is set.
+func startMetrics() {
+	if metricsAddr != "" {
+		handle(metricsAddr, "/metrics", serveMetrics)
+	}
+}
+
+// startStats makes run the current run of the statistics, carrying those
+// of the previous run over into the totals.
+func startStats(run *runState) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if currentRun != nil {
+		for _, s := range currentRun.streams {
+			atomic.AddInt64(&s.past.Lines, atomic.LoadInt64(&s.stats.Lines))
+			atomic.AddInt64(&s.past.Bytes, atomic.LoadInt64(&s.stats.Bytes))
+			atomic.AddInt64(&s.past.dropped, atomic.LoadInt64(&s.stats.dropped))
+			atomic.AddInt64(&s.past.Truncated, atomic.LoadInt64(&s.stats.Truncated))
+		}
+	}
+	currentRun = run
+	atomic.AddInt64(&runs, 1)
+	atomic.StoreInt64(&childStart, run.start.UnixNano())
+}
+
+// outputStreams returns the output streams of the current run, or fresh
+// ones before the first run.
+func outputStreams() []outputStream {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if currentRun == nil {
+		return newOutputStreams()
+	}
+	return currentRun.streams
+}
+
+// streamTotals returns the statistics of each of outputStreams over all
+// runs.
+func streamTotals() []streamStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	streams := newOutputStreams()
+	if currentRun != nil {
+		streams = currentRun.streams
+	}
+	totals := make([]streamStats, len(streams))
+	for i, s := range streams {
+		totals[i].Lines = atomic.LoadInt64(&s.past.Lines) + atomic.LoadInt64(&s.stats.Lines)
+		totals[i].Bytes = atomic.LoadInt64(&s.past.Bytes) + atomic.LoadInt64(&s.stats.Bytes)
+		totals[i].dropped = atomic.LoadInt64(&s.past.dropped) + atomic.LoadInt64(&s.stats.dropped)
+		totals[i].Truncated = atomic.LoadInt64(&s.past.Truncated) + atomic.LoadInt64(&s.stats.Truncated)
+	}
+	return totals
+}
+
+// totalDropped returns the number of lines dropped from all streams over
+// all runs.
+func totalDropped() int64 {
+	var dropped int64
+	for _, t := range streamTotals() {
+		dropped += t.dropped
+	}
+	return dropped
+}
+
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	streams := outputStreams()
+	totals := streamTotals()
+	counter := func(name, help string, value func(s *streamStats) int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for i, s := range streams {
+			fmt.Fprintf(&b, "%s{stream=%q} %d\n", name, s.name, value(&totals[i]))
+		}
+	}
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+
+	counter("logexec_lines_total", "Lines logged from the command's output.",
+		func(s *streamStats) int64 { return s.Lines })
+	counter("logexec_bytes_total", "Bytes logged from the command's output.",
+		func(s *streamStats) int64 { return s.Bytes })
+	counter("logexec_dropped_lines_total", "Lines dropped because syslog could not keep up.",
+		func(s *streamStats) int64 { return s.dropped })
+	counter("logexec_truncated_lines_total", "Lines truncated to the maximum line length.",
+		func(s *streamStats) int64 { return s.Truncated })
+	metric("logexec_sink_errors_total", "counter", "Failed writes to syslog.",
+		float64(atomic.LoadInt64(&sinkErrors)))
+	metric("logexec_reconnects_total", "counter", "Times writing to syslog succeeded again after failing.",
+		float64(atomic.LoadInt64(&reconnects)))
+	metric("logexec_runs_total", "counter", "Times the command has been started.",
+		float64(atomic.LoadInt64(&runs)))
+	if starts > 0 {
+		metric("logexec_starts_total", "counter", "Times logexec has started with the state file.",
+			float64(starts))
+	}
+	metric("logexec_queue_depth", "gauge", "Lines waiting to be written to syslog.",
+		float64(queueDepth()))
+	metric("logexec_queue_high_water", "gauge", "Most lines that have been waiting in one stream's queue.",
+		float64(atomic.LoadInt64(&queueHighWater)))
+	metric("logexec_queue_capacity", "gauge", "Lines each stream's queue can hold.",
+		float64(queueSize))
+	running := atomic.LoadInt64(&childrenRunning)
+	metric("logexec_children_running", "gauge", "Commands currently running.", float64(running))
+	uptime := 0.0
+	if running > 0 {
+		uptime = time.Since(time.Unix(0, atomic.LoadInt64(&childStart))).Seconds()
+	}
+	metric("logexec_child_uptime_seconds", "gauge", "Time since the current run started.", uptime)
+	for _, m := range lineMetrics {
+		count, sum := m.values()
+		name := "logexec_metric_" + m.name
+		if !m.extracts() {
+			metric(name+"_total", "counter", fmt.Sprintf("Lines matching %q.", m.re), float64(count))
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s Values extracted from lines matching %q.\n# TYPE %s summary\n", name, m.re, name)
+		fmt.Fprintf(&b, "%s_sum %v\n%s_count %d\n", name, sum, name, count)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(b.Bytes())
+}
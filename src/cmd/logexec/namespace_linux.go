@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	privateTmp bool
+	privateNet bool
+	privatePID bool
+)
+
+func init() {
+	flag.BoolVar(&privateTmp, "private-tmp", false,
+		"Give the child private /tmp and /var/tmp in a new mount namespace")
+	flag.BoolVar(&privateNet, "private-net", false,
+		"Run the child in a new network namespace with only loopback")
+	flag.BoolVar(&privatePID, "private-pid", false,
+		"Run the child as PID 1 of a new PID namespace")
+}
+
+type ifreqFlags struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// loopbackUp brings up the loopback interface of the calling thread's
+// network namespace.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var ifr ifreqFlags
+	copy(ifr.name[:], "lo")
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+	ifr.flags |= syscall.IFF_UP
+	_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// devlogMountTarget returns the /dev/log the -devlog socket is to be bound
+// over, or "" if there is none. Only root can create the mount namespace,
+// and a /dev/log has to exist to be bound over; other users get the socket
+// in the environment only.
+func devlogMountTarget() string {
+	if devlogPath == "" || os.Geteuid() != 0 {
+		return ""
+	}
+	target := filepath.Join("/", chrootDir, "/dev/log")
+	if _, err := os.Lstat(target); err != nil {
+		debugf("Not binding the devlog socket over %s: %v", target, err)
+		return ""
+	}
+	return target
+}
+
+// applyNamespaces moves the calling thread into the requested new
+// namespaces, so that the child forked from it starts inside them.
+func applyNamespaces() error {
+	devlogTarget := devlogMountTarget()
+	if privateTmp || devlogTarget != "" {
+		if err := syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+			return fmt.Errorf("creating mount namespace: %v", err)
+		}
+		// Keep our mounts from propagating back to the host.
+		err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+		if err != nil {
+			return fmt.Errorf("making mounts private: %v", err)
+		}
+	}
+	if devlogTarget != "" {
+		err := syscall.Mount(devlogPath, devlogTarget, "", syscall.MS_BIND, "")
+		if err != nil {
+			return fmt.Errorf("mounting private /dev/log: %v", err)
+		}
+	}
+	if privateTmp {
+		for _, dir := range []string{"/tmp", "/var/tmp"} {
+			target := filepath.Join("/", chrootDir, dir)
+			err := syscall.Mount("tmpfs", target, "tmpfs",
+				syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777")
+			if err != nil {
+				return fmt.Errorf("mounting private %v: %v", dir, err)
+			}
+		}
+	}
+
+	if privateNet {
+		if err := syscall.Unshare(syscall.CLONE_NEWNET); err != nil {
+			return fmt.Errorf("creating network namespace: %v", err)
+		}
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("bringing up loopback: %v", err)
+		}
+	}
+	return nil
+}
+
+// setupPIDNamespace starts cmd in a new PID namespace. Unlike the other
+// namespaces this is requested at clone time, since a thread that unshares
+// its PID namespace can only fork once.
+func setupPIDNamespace(cmd *exec.Cmd) {
+	if privatePID {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWPID
+	}
+}
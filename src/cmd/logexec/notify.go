@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	notifyTimeout = 10 * time.Second
+
+	// matchNotifyInterval is the least time between notifications of lines
+	// matching -alert, so that a burst of errors pages once.
+	matchNotifyInterval = time.Minute
+
+	pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+)
+
+var notifyEventNames = []string{"exit", "signal", "match"}
+
+var errInvalidNotifyEvents = errors.New("invalid notify events, must be a list of exit, signal and match")
+
+// notifyEvents is the set of events that send notifications.
+type notifyEvents map[string]bool
+
+func (e notifyEvents) String() string {
+	var names []string
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (e notifyEvents) Set(to string) error {
+	for k := range e {
+		delete(e, k)
+	}
+	for _, name := range strings.Split(to, ",") {
+		ok := false
+		for _, n := range notifyEventNames {
+			ok = ok || n == name
+		}
+		if !ok {
+			return errInvalidNotifyEvents
+		}
+		e[name] = true
+	}
+	return nil
+}
+
+// alertList collects repeated -alert regexps.
+type alertList []*regexp.Regexp
+
+func (l alertList) String() string {
+	var s []string
+	for _, re := range l {
+		s = append(s, re.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func (l *alertList) Set(to string) error {
+	re, err := regexp.Compile(to)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, re)
+	return nil
+}
+
+var (
+	notifyURL    string
+	pagerDutyKey string
+	notifyOn     = notifyEvents{"exit": true, "signal": true, "match": true}
+	alerts       alertList
+
+	notifyWG sync.WaitGroup
+
+	// matchMu guards the time of the last match notification and the
+	// number of matches since.
+	matchMu         sync.Mutex
+	lastMatchNotify time.Time
+	matchesSince    int
+)
+
+func init() {
+	flag.StringVar(&notifyURL, "notify-url", "",
+		"webhook URL to POST a JSON event to when the command fails, is killed or logs an -alert line")
+	flag.StringVar(&pagerDutyKey, "pagerduty-key", "",
+		"PagerDuty Events API v2 routing key to trigger an incident with on the same events")
+	flag.Var(notifyOn, "notify-on",
+		"events to notify on: exit (non-zero status), signal and match (an -alert line)")
+	flag.Var(&alerts, "alert",
+		"notify when a line of output matches this regexp; may be repeated")
+}
+
+// notification is the JSON body POSTed to -notify-url.
+type notification struct {
+	Event   string `json:"event"`
+	Time    string `json:"time"`
+	Host    string `json:"host"`
+	Tag     string `json:"tag"`
+	Cmdline string `json:"cmdline,omitempty"`
+	Pid     int    `json:"pid,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Signal  string `json:"signal,omitempty"`
+	Line    string `json:"line,omitempty"`
+	Matches int    `json:"matches,omitempty"`
+	RunID   string `json:"run_id,omitempty"`
+}
+
+func notifying() bool {
+	return notifyURL != "" || pagerDutyKey != ""
+}
+
+// notifyExit sends a notification for a command that exited with a
+// non-zero status or was killed by a signal. A command that logexec told to
+// stop, or that was killed by a signal logexec passed on to it, has not
+// failed and is not notified of.
+func notifyExit(c *child) {
+	event := "exit"
+	if c.signal != 0 {
+		event = "signal"
+	}
+	if !notifying() || !notifyOn[event] || c.status == 0 || c.stopped || c.sent[c.signal] {
+		return
+	}
+	n := notification{
+		Event:   event,
+		Tag:     c.name,
+		Cmdline: strings.Join(c.cmd.Args, " "),
+		Pid:     c.cmd.Process.Pid,
+		Status:  c.status,
+	}
+	if c.signal != 0 {
+		n.Signal = signalName(c.signal)
+	}
+	sendNotification(n)
+}
+
+// checkAlerts sends a notification if line matches an -alert regexp, at
+// most once per matchNotifyInterval. The number of matches is included.
+func checkAlerts(tag string, line []byte) {
+	if !notifying() || !notifyOn["match"] {
+		return
+	}
+	matched := false
+	for _, re := range alerts {
+		if re.Match(line) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return
+	}
+	matchMu.Lock()
+	matchesSince++
+	if time.Since(lastMatchNotify) < matchNotifyInterval {
+		matchMu.Unlock()
+		return
+	}
+	matches := matchesSince
+	lastMatchNotify, matchesSince = time.Now(), 0
+	matchMu.Unlock()
+	sendNotification(notification{Event: "match", Tag: tag, Line: string(line), Matches: matches})
+}
+
+// sendNotification POSTs n to the webhook and PagerDuty in the background.
+// Failures are logged.
+func sendNotification(n notification) {
+	n.Time = time.Now().Format(time.RFC3339)
+	n.Host, _ = os.Hostname()
+	n.RunID = runID
+	if notifyURL != "" {
+		notifyWG.Add(1)
+		go func() {
+			defer notifyWG.Done()
+			if err := postJSON(notifyURL, n); err != nil {
+				warnf("Error sending %s notification: %v", n.Event, err)
+			}
+		}()
+	}
+	if pagerDutyKey != "" {
+		notifyWG.Add(1)
+		go func() {
+			defer notifyWG.Done()
+			if err := postJSON(pagerDutyURL, pagerDutyEvent(n)); err != nil {
+				warnf("Error sending %s event to PagerDuty: %v", n.Event, err)
+			}
+		}()
+	}
+}
+
+// pagerDutyEvent returns the Events API v2 trigger for n. Events for the
+// same command share a dedup key, so repeats add to one incident.
+func pagerDutyEvent(n notification) interface{} {
+	summary := fmt.Sprintf("%s on %s: command exited with status %d", n.Tag, n.Host, n.Status)
+	switch n.Event {
+	case "signal":
+		summary = fmt.Sprintf("%s on %s: command killed by signal %s", n.Tag, n.Host, n.Signal)
+	case "match":
+		summary = fmt.Sprintf("%s on %s: %s", n.Tag, n.Host, n.Line)
+	}
+	// PagerDuty truncates summaries at 1024 characters.
+	if len(summary) > 1024 {
+		summary = summary[:1024]
+	}
+	return map[string]interface{}{
+		"routing_key":  pagerDutyKey,
+		"event_action": "trigger",
+		"dedup_key":    "logexec-" + n.Host + "-" + n.Tag + "-" + n.Event,
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         n.Host,
+			"severity":       "critical",
+			"component":      n.Tag,
+			"timestamp":      n.Time,
+			"custom_details": n,
+		},
+	}
+}
+
+func postJSON(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// waitNotifications waits for the notifications being sent, so that they
+// are not lost when logexec exits.
+func waitNotifications() {
+	notifyWG.Wait()
+}
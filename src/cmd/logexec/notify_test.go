@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifyEventsSet(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"exit", "exit", true},
+		{"signal,match", "match,signal", true},
+		{"exit,crash", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		e := notifyEvents{}
+		err := e.Set(tt.in)
+		if (err == nil) != tt.ok || err == nil && e.String() != tt.want {
+			t.Errorf("Error on %v, got %v, %v", tt.in, e, err)
+		}
+	}
+}
+
+func TestCheckAlerts(t *testing.T) {
+	var mu sync.Mutex
+	var got []notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notification
+		json.NewDecoder(r.Body).Decode(&n)
+		mu.Lock()
+		got = append(got, n)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	defer func(url string, a alertList) {
+		notifyURL, alerts = url, a
+		lastMatchNotify, matchesSince = time.Time{}, 0
+	}(notifyURL, alerts)
+	notifyURL = srv.URL
+	alerts = alertList{regexp.MustCompile(`FATAL`)}
+
+	for _, l := range []string{"ok", "FATAL: disk full", "FATAL: again", "fine"} {
+		checkAlerts("job", []byte(l))
+	}
+	waitNotifications()
+	if len(got) != 1 || got[0].Event != "match" || got[0].Tag != "job" ||
+		got[0].Line != "FATAL: disk full" || got[0].Matches != 1 {
+		t.Errorf("Error on alerts, got %+v", got)
+	}
+	if matchesSince != 1 {
+		t.Errorf("Error on alerts, got %d matches pending", matchesSince)
+	}
+}
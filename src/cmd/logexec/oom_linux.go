@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+var (
+	oomScoreAdj     optionalInt
+	selfOOMScoreAdj optionalInt
+
+	// origOOMScoreAdj is logexec's own score from before it was
+	// protected with -self-oom-score-adj.
+	origOOMScoreAdj optionalInt
+)
+
+func init() {
+	flag.Var(&oomScoreAdj, "oom-score-adj",
+		"OOM killer score adjustment for the child, -1000 to 1000")
+	flag.Var(&selfOOMScoreAdj, "self-oom-score-adj",
+		"OOM killer score adjustment for logexec itself, -1000 to 1000")
+}
+
+// writeOOMScoreAdj sets the OOM score adjustment of the calling process.
+func writeOOMScoreAdj(adj int) error {
+	if adj < -1000 || adj > 1000 {
+		return fmt.Errorf("invalid OOM score adjustment %d", adj)
+	}
+	return ioutil.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(adj)), 0644)
+}
+
+// childOOMScoreAdj returns the OOM score adjustment the trampoline of a
+// child sets before the command is exec'd, if any, first protecting logexec
+// itself when it is the first child. Later children, which inherit the
+// protected score, are given back the score logexec had before unless
+// -oom-score-adj is set. Lowering a score needs privileges, so failing to
+// protect logexec is only reported.
+func childOOMScoreAdj() *int {
+	if selfOOMScoreAdj.set && !origOOMScoreAdj.set {
+		b, err := ioutil.ReadFile("/proc/self/oom_score_adj")
+		if err == nil {
+			origOOMScoreAdj.value, err = strconv.Atoi(strings.TrimSpace(string(b)))
+		}
+		if err == nil {
+			origOOMScoreAdj.set = true
+			err = writeOOMScoreAdj(selfOOMScoreAdj.value)
+			if err != nil {
+				warnf("Error setting logexec OOM score: %v", err)
+			}
+		} else {
+			warnf("Error reading logexec OOM score: %v", err)
+		}
+	}
+	adj := oomScoreAdj
+	if !adj.set {
+		adj = origOOMScoreAdj
+	}
+	if !adj.set {
+		return nil
+	}
+	return &adj.value
+}
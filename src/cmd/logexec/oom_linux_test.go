@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestTrampolineOOMScoreAdj(t *testing.T) {
+	b, err := ioutil.ReadFile("/proc/self/oom_score_adj")
+	if err != nil {
+		t.Skipf("Error reading own OOM score: %v", err)
+	}
+	self := strings.TrimSpace(string(b))
+	adj := func(v int) *int { return &v }
+	umask := 022
+	tests := []struct {
+		tr   trampoline
+		want string
+	}{
+		{trampoline{Umask: &umask}, self},
+		{trampoline{OOMScoreAdj: adj(500)}, "500"},
+		{trampoline{OOMScoreAdj: adj(1001)}, ""},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		cmd := exec.Command("/bin/cat", "/proc/self/oom_score_adj")
+		cmd.Stdout = &out
+		err := startTrampoline(cmd, tt.tr)
+		if err == nil {
+			err = cmd.Wait()
+		}
+		if got := strings.TrimSpace(out.String()); got != tt.want || (err != nil) != (tt.want == "") {
+			t.Errorf("Error on %+v, got %q, %v", tt.tr, got, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/syslog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"logexec"
+)
+
+// processors is the chain given by -drop, -rewrite, -relevel and -enrich,
+// in the order they appear on the command line.
+var processors logexec.Chain
+
+var errInvalidRewrite = errors.New("invalid rewrite, must be /regexp/replacement/")
+
+// processorFlag adds a processor to the chain for each use of a flag.
+type processorFlag func(to string) (logexec.Processor, error)
+
+func (f processorFlag) String() string {
+	return ""
+}
+
+func (f processorFlag) Set(to string) error {
+	p, err := f(to)
+	if err != nil {
+		return err
+	}
+	processors = append(processors, p)
+	return nil
+}
+
+func init() {
+	flag.Var(processorFlag(parseDrop), "drop",
+		"drop lines matching this regexp; may be repeated")
+	flag.Var(processorFlag(parseRewrite), "rewrite",
+		"rewrite lines with /regexp/replacement/, where any character can stand for /; may be repeated")
+	flag.Var(processorFlag(parseRelevel), "relevel",
+		"log lines matching regexp=level at that level; may be repeated")
+	flag.Var(processorFlag(parseEnrich), "enrich",
+		"append key=value to every line; may be repeated")
+}
+
+func parseDrop(to string) (logexec.Processor, error) {
+	re, err := regexp.Compile(to)
+	if err != nil {
+		return nil, err
+	}
+	return logexec.Drop(re), nil
+}
+
+func parseRewrite(to string) (logexec.Processor, error) {
+	if len(to) < 3 {
+		return nil, errInvalidRewrite
+	}
+	parts := strings.Split(to[1:], to[:1])
+	if len(parts) != 3 || parts[2] != "" {
+		return nil, errInvalidRewrite
+	}
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	return logexec.Rewrite(re, parts[1]), nil
+}
+
+func parseRelevel(to string) (logexec.Processor, error) {
+	i := strings.LastIndexByte(to, '=')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid relevel %q, must be regexp=level", to)
+	}
+	var level logLevel
+	if err := level.Set(to[i+1:]); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(to[:i])
+	if err != nil {
+		return nil, err
+	}
+	return logexec.Relevel(re, syslog.Priority(level)), nil
+}
+
+func parseEnrich(to string) (logexec.Processor, error) {
+	kv := strings.SplitN(to, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return nil, fmt.Errorf("invalid enrich %q, must be key=value", to)
+	}
+	return logexec.Enrich(kv[0], kv[1]), nil
+}
+
+// recordWriter passes each line of a stream through the processors, and
+// writes what they keep to syslog and then to every sink.
+type recordWriter struct {
+	w      *spoolWriter
+	record logexec.Record
+	// level is the level of the stream, which a reload may change, or nil
+	// if it is that of record.
+	level   *logLevel
+	seq     *streamSeq
+	failing map[*lockedSink]bool
+}
+
+// newRecordWriter returns w, wrapped to apply the processors and write to
+// the sinks with records for tag and stream when there are any, or when a
+// reload of -config may add them.
+func newRecordWriter(w *spoolWriter, tag, stream string, priority syslog.Priority) io.Writer {
+	if len(processors) == 0 && len(sinks) == 0 && len(alerts) == 0 && len(lineMetrics) == 0 && !sequencing() &&
+		configFile == "" {
+		return w
+	}
+	rw := &recordWriter{
+		w:       w,
+		record:  logexec.Record{Tag: tag, Stream: stream, Priority: priority},
+		failing: map[*lockedSink]bool{},
+	}
+	if configFile != "" {
+		switch stream {
+		case "stdout":
+			rw.level = &stdoutLevel
+		case "stderr":
+			rw.level = &stderrLevel
+		}
+	}
+	if sequencing() {
+		rw.seq = streamSequence(tag, stream)
+	}
+	return rw
+}
+
+// Write returns the error from syslog only; errors from sinks are counted
+// and logged when a sink starts failing.
+func (rw *recordWriter) Write(b []byte) (int, error) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	r := rw.record
+	r.Time = time.Now()
+	r.Line = b
+	if rw.level != nil {
+		r.Priority = r.Priority&^7 | syslog.Priority(*rw.level)
+	}
+	if !processors.Process(&r) {
+		return len(b), nil
+	}
+	checkAlerts(r.Tag, r.Line)
+	observeMetrics(r.Line)
+
+	if rw.seq != nil {
+		rw.seq.mu.Lock()
+		defer rw.seq.mu.Unlock()
+		r.Line = rw.seq.next(rw, r.Line)
+	}
+	if err := rw.emit(r); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// emit writes r to syslog and the sinks.
+func (rw *recordWriter) emit(r logexec.Record) error {
+	var err error
+	if r.Priority == rw.record.Priority {
+		_, err = rw.w.Write(r.Line)
+	} else {
+		_, err = rw.w.writeLevel(r.Priority&7, r.Line)
+	}
+	for _, s := range sinks {
+		s.mu.Lock()
+		serr := s.sink.Write(r)
+		s.mu.Unlock()
+		if serr != nil {
+			atomic.AddInt64(&sinkErrors, 1)
+			if !rw.failing[s] {
+				warnf("Error writing to sink %s: %v", s.url, serr)
+			}
+		}
+		rw.failing[s] = serr != nil
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log/syslog"
+	"testing"
+
+	"logexec"
+)
+
+func TestParseProcessors(t *testing.T) {
+	tests := []struct {
+		parse processorFlag
+		arg   string
+		line  string
+		keep  bool
+		want  string
+	}{
+		{parseDrop, "^debug", "debug: x", false, "debug: x"},
+		{parseDrop, "^debug", "info: x", true, "info: x"},
+		{parseRewrite, "/a(b)/<$1>/", "xaby", true, "x<b>y"},
+		{parseRewrite, "|/tmp/|/var/|", "in /tmp/x", true, "in /var/x"},
+		{parseRelevel, "a=b=err", "a=b", true, "a=b"},
+		{parseEnrich, "env=prod", "x", true, "x env=prod"},
+	}
+	for _, tt := range tests {
+		p, err := tt.parse(tt.arg)
+		if err != nil {
+			t.Errorf("Error on %v, got %v", tt.arg, err)
+			continue
+		}
+		r := logexec.Record{Line: []byte(tt.line)}
+		if keep := p.Process(&r); keep != tt.keep || string(r.Line) != tt.want {
+			t.Errorf("Error on %v, got %v, %q", tt.arg, keep, r.Line)
+		}
+	}
+
+	p, _ := parseRelevel("boom=crit")
+	r := logexec.Record{Line: []byte("boom"), Priority: syslog.LOG_LOCAL0 | syslog.LOG_INFO}
+	if p.Process(&r); r.Priority != syslog.LOG_LOCAL0|syslog.LOG_CRIT {
+		t.Errorf("Error on relevel, got %v", r.Priority)
+	}
+
+	bad := []struct {
+		parse processorFlag
+		arg   string
+	}{
+		{parseDrop, "("},
+		{parseRewrite, "/a/b"},
+		{parseRewrite, "/a/b/c/"},
+		{parseRewrite, "/(/b/"},
+		{parseRelevel, "noequals"},
+		{parseRelevel, "a=loud"},
+		{parseEnrich, "=x"},
+	}
+	for _, tt := range bad {
+		if _, err := tt.parse(tt.arg); err == nil {
+			t.Errorf("Error on %v, got no error", tt.arg)
+		}
+	}
+}
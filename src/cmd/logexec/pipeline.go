@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// startPipeline starts stages with the stdout of each one piped into the
+// stdin of the next. Every stage's stderr is logged under its own tag and
+// only the final stage's stdout is logged.
+func (run *runState) startPipeline(stages []runSpec) ([]*child, error) {
+	var children []*child
+	var stdin *os.File
+	for i, spec := range stages {
+		var r, w *os.File
+		if i < len(stages)-1 {
+			var err error
+			r, w, err = os.Pipe()
+			if err != nil {
+				return children, err
+			}
+		}
+
+		c, err := run.startCmd(spec, stdin, w)
+		if w != nil {
+			w.Close()
+		}
+		if stdin != nil {
+			stdin.Close()
+		}
+		if err != nil {
+			if r != nil {
+				r.Close()
+			}
+			return children, err
+		}
+		children = append(children, c)
+		stdin = r
+	}
+	return children, nil
+}
+
+// pipelineStatus logs the exit status of every stage and returns the status
+// of the last stage that failed, like a shell with pipefail set.
+func pipelineStatus(stages []*child) int {
+	var parts []string
+	status := 0
+	for _, c := range stages {
+		parts = append(parts, fmt.Sprintf("%s=%d", c.name, c.status))
+		if c.status != 0 {
+			status = c.status
+		}
+	}
+	fmt.Fprintf(stderrLog, "Pipeline exit statuses: %s", strings.Join(parts, " "))
+	return status
+}
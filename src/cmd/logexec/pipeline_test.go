@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenSyslog listens for syslog messages on a socket of its own, and
+// returns its path and a function returning the next message written to it.
+func listenSyslog(t *testing.T) (string, func() string, func()) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	next := func() string {
+		b := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _ := conn.Read(b)
+		return strings.TrimSuffix(string(b[:n]), "\n")
+	}
+	return path, next, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPipelineStatus(t *testing.T) {
+	path, next, done := listenSyslog(t)
+	defer done()
+	w, err := syslog.Dial("unixgram", path, syslog.LOG_WARNING, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer func(l *syslog.Writer) { stderrLog = l }(stderrLog)
+	stderrLog = w
+
+	tests := []struct {
+		statuses []int
+		want     int
+		msg      string
+	}{
+		{[]int{0, 0, 0}, 0, "a=0 b=0 c=0"},
+		{[]int{0, 3}, 3, "a=0 b=3"},
+		{[]int{2, 0, 141}, 141, "a=2 b=0 c=141"},
+		{[]int{2, 0, 0}, 2, "a=2 b=0 c=0"},
+	}
+	for _, tt := range tests {
+		var stages []*child
+		for i, status := range tt.statuses {
+			stages = append(stages, &child{name: string(rune('a' + i)), status: status})
+		}
+		got := pipelineStatus(stages)
+		if msg := next(); got != tt.want || !strings.HasSuffix(msg, "Pipeline exit statuses: "+tt.msg) {
+			t.Errorf("Error on %v, got %v, %q", tt.statuses, got, msg)
+		}
+	}
+}
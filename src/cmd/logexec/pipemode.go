@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// isPipeMode reports whether args asks for pipe mode, where logexec runs no
+// command and logs its own stdin instead, in place of logger(1).
+func isPipeMode(args []string) bool {
+	return len(args) == 1 && args[0] == "-"
+}
+
+// runPipe logs the lines read from in as the stdout of a command would be,
+// until in ends or ctx is done, as a run of its own, and returns the exit
+// status. If logging could not be set up, it returns an error along with
+// the status, 1.
+func runPipe(ctx context.Context, in io.Reader, name string) (int, error) {
+	outTag, _ := runSpec{name: tag, stdoutTag: stdoutTag, stderrTag: stderrTag}.tags()
+	log, err := UnixSyslog(stdoutPriority(), outTag)
+	if err != nil {
+		return 1, fmt.Errorf("initializing %s syslog: %v", name, err)
+	}
+	sw, err := newSpoolWriter(log, outTag, "stdout")
+	if err != nil {
+		log.Close()
+		return 1, err
+	}
+	defer sw.Close()
+
+	if ctx.Done() != nil {
+		// A read from in cannot be interrupted, so in is copied through a
+		// pipe that is closed once ctx is done. The lines in it are still
+		// logged.
+		r, w, err := os.Pipe()
+		if err != nil {
+			return 1, fmt.Errorf("initializing %s pipe: %v", name, err)
+		}
+		defer r.Close()
+		copied := make(chan struct{})
+		go func(in io.Reader) {
+			io.Copy(w, in)
+			close(copied)
+		}(in)
+		go func() {
+			select {
+			case <-ctx.Done():
+				debugf("Stopping, logging what was read from %s", name)
+			case <-copied:
+			}
+			w.Close()
+		}()
+		in = r
+	}
+
+	run := newRunState(ctx)
+	startStats(run)
+	defer run.cancel(nil)
+	run.loggers.Add(1)
+	go run.logPipe(newRecordWriter(sw, outTag, "stdout", stdoutPriority()), in, run.stats("stdout"))
+	err = <-run.logErr
+	run.loggers.Wait()
+	writeCheckpoints()
+	flushSpools()
+	flushSinks()
+	writeState()
+	waitNotifications()
+	if err != io.EOF {
+		errorf("Error logging %s: %v", name, err)
+		return 1, nil
+	}
+	return 0, nil
+}
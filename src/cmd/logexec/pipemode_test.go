@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPipeMode(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-"}, true},
+		{nil, false},
+		{[]string{"-", "cat"}, false},
+		{[]string{"cat", "-"}, false},
+		{[]string{"--"}, false},
+	}
+	for _, tt := range tests {
+		if got := isPipeMode(tt.args); got != tt.want {
+			t.Errorf("Error on %q, got %v", tt.args, got)
+		}
+	}
+}
+
+func TestRunPipe(t *testing.T) {
+	path, next, done := listenSyslog(t)
+	defer done()
+	defer func(paths []string) { syslogPaths = paths }(syslogPaths)
+	syslogPaths = []string{path}
+
+	tests := []struct {
+		input string
+		// cancel is whether the context is done while the input is still
+		// open, after it has been written.
+		cancel bool
+		want   []string
+	}{
+		{"one\ntwo\n", false, []string{"one", "two"}},
+		{"one\nno newline", false, []string{"one", "no newline"}},
+		{"", false, nil},
+		{"one\n", true, []string{"one"}},
+	}
+	for _, tt := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		io.WriteString(w, tt.input)
+		if tt.cancel {
+			time.AfterFunc(100*time.Millisecond, cancel)
+		} else {
+			w.Close()
+		}
+		status, err := runPipe(ctx, r, "test input")
+		cancel()
+		w.Close()
+		r.Close()
+
+		var got []string
+		for range tt.want {
+			msg := next()
+			got = append(got, msg[strings.LastIndex(msg, ": ")+2:])
+		}
+		if status != 0 || err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Error on %q cancel %v, got %v, %v, %q", tt.input, tt.cancel, status, err, got)
+		}
+	}
+}
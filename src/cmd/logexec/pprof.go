@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"net/http/pprof"
+)
+
+var pprofAddr string
+
+func init() {
+	flag.StringVar(&pprofAddr, "pprof-addr", "",
+		"address to serve Go profiling data on at /debug/pprof/, e.g. localhost:6060")
+}
+
+// startPprof serves the profiling endpoints if -pprof-addr is set.
+func startPprof() {
+	if pprofAddr == "" {
+		return
+	}
+	handle(pprofAddr, "/debug/pprof/", pprof.Index)
+	handle(pprofAddr, "/debug/pprof/cmdline", pprof.Cmdline)
+	handle(pprofAddr, "/debug/pprof/profile", pprof.Profile)
+	handle(pprofAddr, "/debug/pprof/symbol", pprof.Symbol)
+	handle(pprofAddr, "/debug/pprof/trace", pprof.Trace)
+}
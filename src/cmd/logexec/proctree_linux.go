@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	treeInterval time.Duration
+
+	// treeProcs holds the descendants of the children seen at the last
+	// scan, by pid.
+	treeProcs = map[int]treeProc{}
+)
+
+func init() {
+	flag.DurationVar(&treeInterval, "track-tree", 0,
+		"scan /proc at this interval for processes forked by the commands, logging those that start and exit (e.g. 5s)")
+}
+
+// treeProc is a process in the tree of a child. Its start time tells it
+// apart from a later process that reuses the pid.
+type treeProc struct {
+	ppid    int
+	start   uint64
+	cmdline string
+	seen    time.Time
+	root    *child
+}
+
+// procEntry is the parent and start time of a process, from
+// /proc/<pid>/stat.
+type procEntry struct {
+	ppid  int
+	start uint64
+}
+
+// parseProcParent reads the parent pid and start time from the contents of
+// /proc/<pid>/stat.
+func parseProcParent(stat string) (procEntry, error) {
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return procEntry{}, errInvalidProcStat
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return procEntry{}, errInvalidProcStat
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return procEntry{}, errInvalidProcStat
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return procEntry{}, errInvalidProcStat
+	}
+	return procEntry{ppid: ppid, start: start}, nil
+}
+
+// descendants returns the processes below root in procs, not counting
+// root itself.
+func descendants(procs map[int]procEntry, root int) []int {
+	children := map[int][]int{}
+	for pid, p := range procs {
+		children[p.ppid] = append(children[p.ppid], pid)
+	}
+	var found []int
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, c := range children[pid] {
+			found = append(found, c)
+			queue = append(queue, c)
+		}
+	}
+	return found
+}
+
+// scanProcs reads the parent and start time of every process.
+func scanProcs() (map[int]procEntry, error) {
+	dir, err := os.Open("/proc")
+	if err != nil {
+		return nil, err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+	procs := map[int]procEntry{}
+	for _, name := range names {
+		pid, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile("/proc/" + name + "/stat")
+		if err != nil {
+			// The process has exited since the directory was read.
+			continue
+		}
+		if p, err := parseProcParent(string(b)); err == nil {
+			procs[pid] = p
+		}
+	}
+	if len(procs) == 0 {
+		return nil, errors.New("no processes found in /proc")
+	}
+	return procs, nil
+}
+
+func procCmdline(pid int) string {
+	b, _ := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	return strings.TrimSpace(strings.Replace(string(b), "\x00", " ", -1))
+}
+
+// startTreeTracker returns a channel that ticks at the -track-tree
+// interval, or nil if the tree is not tracked.
+func startTreeTracker() <-chan time.Time {
+	if treeInterval <= 0 {
+		return nil
+	}
+	treeProcs = map[int]treeProc{}
+	return time.NewTicker(treeInterval).C
+}
+
+// trackTree logs the processes that have appeared below the children since
+// the last scan, and those that have gone. A process that is still running
+// but has left the tree, as when its parent exits and it is reparented, is
+// logged as orphaned. Processes that come and go between two scans are not
+// seen.
+func trackTree(children []*child) {
+	procs, err := scanProcs()
+	if err != nil {
+		debugf("Tracking the process tree: %v", err)
+		return
+	}
+	now := time.Now()
+	current := map[int]treeProc{}
+	for _, c := range children {
+		pid := c.cmd.Process.Pid
+		if _, ok := procs[pid]; !ok {
+			continue
+		}
+		for _, d := range descendants(procs, pid) {
+			p := procs[d]
+			if old, ok := treeProcs[d]; ok && old.start == p.start {
+				current[d] = old
+				continue
+			}
+			tp := treeProc{ppid: p.ppid, start: p.start, cmdline: procCmdline(d), seen: now, root: c}
+			current[d] = tp
+			fmt.Fprintf(c.stdout, "Descendant started: pid=%d ppid=%d cmdline=%q", d, tp.ppid, tp.cmdline)
+		}
+	}
+	for pid, tp := range treeProcs {
+		if _, ok := current[pid]; ok {
+			continue
+		}
+		lived := now.Sub(tp.seen).Round(time.Second)
+		if p, ok := procs[pid]; ok && p.start == tp.start {
+			fmt.Fprintf(tp.root.stdout, "Descendant orphaned: pid=%d ppid=%d cmdline=%q seen_for=%v",
+				pid, p.ppid, tp.cmdline, lived)
+			continue
+		}
+		// A process that exits while its parent lives on, such as a
+		// worker of a supervisor, is logged at the stderr level.
+		w := tp.root.stdout
+		if _, ok := procs[tp.ppid]; ok {
+			w = tp.root.stderr
+		}
+		fmt.Fprintf(w, "Descendant exited: pid=%d ppid=%d cmdline=%q seen_for=%v",
+			pid, tp.ppid, tp.cmdline, lived)
+	}
+	treeProcs = current
+}
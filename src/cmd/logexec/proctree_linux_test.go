@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseProcParent(t *testing.T) {
+	tests := []struct {
+		stat string
+		want procEntry
+		ok   bool
+	}{
+		{"1234 (sleep) S 1 1234 1234 0 -1 4194304 90 0 0 0 150 50 0 0 20 0 3 0 100 5685248 200 18446744073709551615",
+			procEntry{ppid: 1, start: 100}, true},
+		{"42 (a (b) c) R 7 42 42 0 -1 0 0 0 0 0 1 2 0 0 20 0 1 0 555 0 10 0",
+			procEntry{ppid: 7, start: 555}, true},
+		{"42 (truncated) R 1 42", procEntry{}, false},
+		{"garbage", procEntry{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseProcParent(tt.stat)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Error on %v, got %+v, %v", tt.stat, got, err)
+		}
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	procs := map[int]procEntry{
+		1:  {ppid: 0},
+		10: {ppid: 1},
+		11: {ppid: 10},
+		12: {ppid: 10},
+		13: {ppid: 12},
+		20: {ppid: 1},
+	}
+	tests := []struct {
+		root int
+		want []int
+	}{
+		{10, []int{11, 12, 13}},
+		{12, []int{13}},
+		{13, nil},
+		{99, nil},
+	}
+	for _, tt := range tests {
+		got := descendants(procs, tt.root)
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Error on %v, got %v", tt.root, got)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const dropReportInterval = time.Minute
+
+// overflowPolicy decides what happens to a line that arrives when the queue
+// is full.
+type overflowPolicy int
+
+const (
+	overflowBlock overflowPolicy = iota
+	overflowDropOldest
+	overflowDropNewest
+)
+
+var overflowNames = map[string]overflowPolicy{
+	"block":       overflowBlock,
+	"drop-oldest": overflowDropOldest,
+	"drop-newest": overflowDropNewest,
+}
+
+var errInvalidOverflowPolicy = errors.New("invalid queue policy, must be block, drop-oldest or drop-newest")
+
+func (p overflowPolicy) String() string {
+	for name, v := range overflowNames {
+		if v == p {
+			return name
+		}
+	}
+	return ""
+}
+
+func (p *overflowPolicy) Set(s string) error {
+	v, ok := overflowNames[s]
+	if !ok {
+		return errInvalidOverflowPolicy
+	}
+	*p = v
+	return nil
+}
+
+var (
+	queueSize   = 1024
+	queuePolicy = overflowBlock
+	nonBlocking bool
+
+	queueReport time.Duration
+
+	queuesMu sync.Mutex
+	queues   = map[*lineQueue]bool{}
+
+	// queueHighWater is the most lines that have been waiting in one queue.
+	queueHighWater int64
+
+	// reportedTotalDrops is the number of dropped lines at the last queue
+	// report.
+	reportedTotalDrops int64
+	reportedAt         time.Time
+)
+
+func init() {
+	flag.IntVar(&queueSize, "queue-size", queueSize,
+		"number of lines of each stream to buffer while syslog is slow")
+	flag.Var(&queuePolicy, "queue-policy",
+		"what to do when the queue is full: block, drop-oldest or drop-newest")
+	flag.DurationVar(&queueReport, "queue-report", 0,
+		"log the queue depth, high-water mark and drop rate at this interval (e.g. 5m)")
+	flag.BoolVar(&nonBlocking, "nonblock", false,
+		"drop lines when syslog cannot keep up instead of blocking the command (same as -queue-policy=drop-newest)")
+}
+
+// overflow returns the queue policy in effect.
+func overflow() overflowPolicy {
+	if nonBlocking {
+		return overflowDropNewest
+	}
+	return queuePolicy
+}
+
+// lineQueue passes lines to w from a goroutine of its own, so that the
+// reader only waits on a slow writer once the queue is full and the policy
+// is to block.
+type lineQueue struct {
+	w      io.Writer
+	lines  chan []byte
+	policy overflowPolicy
+	stats  *streamStats
+	done   chan struct{}
+
+	// full is only used by the reader, to log overflows once.
+	full bool
+
+	mu  sync.Mutex
+	err error
+}
+
+func newLineQueue(w io.Writer, stats *streamStats) *lineQueue {
+	q := &lineQueue{
+		w:      w,
+		lines:  make(chan []byte, queueSize),
+		policy: overflow(),
+		stats:  stats,
+		done:   make(chan struct{}),
+	}
+	queuesMu.Lock()
+	queues[q] = true
+	queuesMu.Unlock()
+	go q.run()
+	return q
+}
+
+// queueDepth returns the number of lines waiting in all queues.
+func queueDepth() int {
+	queuesMu.Lock()
+	defer queuesMu.Unlock()
+	depth := 0
+	for q := range queues {
+		depth += len(q.lines)
+	}
+	return depth
+}
+
+// run writes the queued lines to w. A failed write is handled according
+// to -on-log-error; with kill it is reported by the next call to Write.
+func (q *lineQueue) run() {
+	defer close(q.done)
+	failing, stopped := false, false
+	for l := range q.lines {
+		if stopped || q.failed() != nil {
+			continue
+		}
+		err := writeRetry(q.w, l)
+		if err == nil {
+			failing = false
+			continue
+		}
+		switch onLogError {
+		case logErrorContinue:
+			if !failing {
+				warnf("Error logging command output, continuing: %v", err)
+				failing = true
+			}
+		case logErrorStopLogging:
+			warnf("Error logging command output, discarding the rest: %v", err)
+			stopped = true
+		default:
+			q.mu.Lock()
+			q.err = err
+			q.mu.Unlock()
+		}
+	}
+}
+
+func (q *lineQueue) failed() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// Write queues a copy of b, applying the overflow policy if the queue is
+// full. It returns the error of an earlier write that failed, if any.
+func (q *lineQueue) Write(b []byte) (int, error) {
+	if err := q.failed(); err != nil {
+		return 0, err
+	}
+	touchOutput()
+	l := append([]byte(nil), b...)
+	if len(q.lines) == cap(q.lines) {
+		if !q.full {
+			debugf("Queue full at %d lines, applying %v policy", cap(q.lines), q.policy)
+			q.full = true
+		}
+	} else if q.full {
+		debugf("Queue no longer full")
+		q.full = false
+	}
+	defer func() {
+		storeMax(&queueHighWater, int64(len(q.lines)))
+	}()
+	switch q.policy {
+	case overflowBlock:
+		q.lines <- l
+	case overflowDropNewest:
+		select {
+		case q.lines <- l:
+		default:
+			atomic.AddInt64(&q.stats.dropped, 1)
+		}
+	case overflowDropOldest:
+		for {
+			select {
+			case q.lines <- l:
+				return len(b), nil
+			default:
+			}
+			select {
+			case <-q.lines:
+				atomic.AddInt64(&q.stats.dropped, 1)
+			default:
+			}
+		}
+	}
+	return len(b), nil
+}
+
+// Close waits for the queued lines to be written.
+func (q *lineQueue) Close() error {
+	close(q.lines)
+	<-q.done
+	queuesMu.Lock()
+	delete(queues, q)
+	queuesMu.Unlock()
+	return q.failed()
+}
+
+// startDropReport returns a channel that ticks when dropped lines should be
+// reported, or nil if lines are never dropped.
+func startDropReport() <-chan time.Time {
+	if overflow() == overflowBlock {
+		return nil
+	}
+	return time.NewTicker(dropReportInterval).C
+}
+
+// reportDrops logs how many lines of each stream of the run were dropped
+// since the last report.
+func (run *runState) reportDrops() {
+	for _, s := range run.streams {
+		dropped := atomic.LoadInt64(&s.stats.dropped)
+		if n := dropped - run.reportedDrops[s.name]; n > 0 {
+			fmt.Fprintf(stderrLog, "Dropped %d %s lines because syslog could not keep up",
+				n, s.name)
+		}
+		run.reportedDrops[s.name] = dropped
+	}
+}
+
+// startQueueReport returns a channel that ticks at the queue report
+// interval, or nil if queue reports are disabled.
+func startQueueReport() <-chan time.Time {
+	if queueReport <= 0 {
+		return nil
+	}
+	reportedTotalDrops, reportedAt = totalDropped(), time.Now()
+	return time.NewTicker(queueReport).C
+}
+
+// reportQueue logs the state of the queues and the rate at which lines have
+// been dropped since the last report.
+func reportQueue() {
+	dropped := totalDropped()
+	rate := float64(dropped-reportedTotalDrops) / time.Since(reportedAt).Seconds()
+	reportedTotalDrops, reportedAt = dropped, time.Now()
+	fmt.Fprintf(stdoutLog, "Queue status: depth=%d high_water=%d capacity=%d dropped_per_second=%.2f",
+		queueDepth(), atomic.LoadInt64(&queueHighWater), queueSize, rate)
+}
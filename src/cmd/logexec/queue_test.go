@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// gatedWriter records lines once its gate has been opened.
+type gatedWriter struct {
+	gate  sync.WaitGroup
+	mu    sync.Mutex
+	lines []string
+}
+
+func (w *gatedWriter) Write(b []byte) (int, error) {
+	w.gate.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lines = append(w.lines, string(b))
+	return len(b), nil
+}
+
+func TestLineQueueOverflow(t *testing.T) {
+	tests := []struct {
+		policy  overflowPolicy
+		want    string
+		dropped int64
+	}{
+		{overflowDropNewest, "a b", 2},
+		{overflowDropOldest, "c d", 2},
+	}
+	for _, tt := range tests {
+		w := &gatedWriter{}
+		w.gate.Add(1)
+		stats := &streamStats{}
+		q := &lineQueue{
+			w:      w,
+			lines:  make(chan []byte, 2),
+			policy: tt.policy,
+			stats:  stats,
+			done:   make(chan struct{}),
+		}
+		for _, l := range []string{"a", "b", "c", "d"} {
+			q.Write([]byte(l))
+		}
+		go q.run()
+		w.gate.Done()
+		q.Close()
+		if got := strings.Join(w.lines, " "); got != tt.want || stats.dropped != tt.dropped {
+			t.Errorf("Error on %v, got %q with %d dropped", tt.policy, got, stats.dropped)
+		}
+	}
+}
+
+func TestOverflowPolicySet(t *testing.T) {
+	var p overflowPolicy
+	for _, s := range []string{"block", "drop-oldest", "drop-newest"} {
+		if err := p.Set(s); err != nil || p.String() != s {
+			t.Errorf("Error on %v, got %v", s, p)
+		}
+	}
+	if err := p.Set("drop"); err == nil {
+		t.Errorf("Error on drop, got %v", p)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"io"
+
+	"logexec"
+)
+
+var rawRelay bool
+
+func init() {
+	flag.BoolVar(&rawRelay, "raw", false,
+		"log the commands' output in chunks of up to -maxline bytes instead of line by line")
+}
+
+// newStreamReader returns what reads a stream of output into w: a
+// LineWriter, or a ChunkWriter with -raw.
+func newStreamReader(w io.Writer, opts logexec.LineOptions) io.ReaderFrom {
+	if rawRelay {
+		return logexec.NewChunkWriter(w, opts)
+	}
+	return logexec.NewLineWriter(w, opts)
+}
+
+// checkRawSinks exits if -raw is set and a sink would break up the
+// messages of several lines it logs.
+func checkRawSinks() {
+	if !rawRelay {
+		return
+	}
+	for _, s := range sinks {
+		if _, ok := s.sink.(logexec.MultiLineSink); !ok {
+			fatalf("Sink %s cannot take the messages of several lines of -raw", s.url)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+var (
+	sigchld = make(chan os.Signal, 1)
+
+	// reapMu is held while a child is started and registered, so that the
+	// reaper cannot take its exit for that of an orphan.
+	reapMu sync.Mutex
+	// reaped holds, by pid, the exits the reaper collected for children
+	// started with startReaped, until waitChild takes them.
+	reaped = map[int]chan reapedExit{}
+)
+
+// reapedExit is the exit of a child collected by the reaper.
+type reapedExit struct {
+	status syscall.WaitStatus
+	rusage syscall.Rusage
+}
+
+// reapedError reports a non-zero exit of a child when it was collected by
+// the reaper instead of exec.Cmd.Wait.
+type reapedError struct {
+	status syscall.WaitStatus
+}
+
+func (e *reapedError) Error() string {
+	if e.status.Signaled() {
+		return fmt.Sprintf("signal: %v", e.status.Signal())
+	}
+	return fmt.Sprintf("exit status %d", e.status.ExitStatus())
+}
+
+func (e *reapedError) Sys() interface{} {
+	return e.status
+}
+
+// startReaped starts cmd and, with -init, registers it so that its exit is
+// handed to waitChild if the reaper collects it first.
+func startReaped(cmd *exec.Cmd) error {
+	return registerReaped(cmd, cmd.Start)
+}
+
+// registerReaped calls start, which starts cmd, with the reaper held off
+// until cmd is registered.
+func registerReaped(cmd *exec.Cmd, start func() error) error {
+	if !initMode {
+		return start()
+	}
+	reapMu.Lock()
+	defer reapMu.Unlock()
+	if err := start(); err != nil {
+		return err
+	}
+	reaped[cmd.Process.Pid] = make(chan reapedExit, 1)
+	return nil
+}
+
+// waitReaped waits for cmd like cmd.Wait.
+func waitReaped(cmd *exec.Cmd) error {
+	_, err := waitChild(cmd)
+	return err
+}
+
+// waitChild waits for cmd, started with startReaped, and returns its
+// resource usage. If the reaper collected the exit first, cmd.Wait fails
+// with ECHILD and the exit is taken from the reaper instead.
+func waitChild(cmd *exec.Cmd) (*syscall.Rusage, error) {
+	err := cmd.Wait()
+	pid := cmd.Process.Pid
+	reapMu.Lock()
+	ch, ok := reaped[pid]
+	reapMu.Unlock()
+	// The entry is kept until the exit is taken, as the reaper looks it up
+	// after collecting it.
+	defer func() {
+		reapMu.Lock()
+		delete(reaped, pid)
+		reapMu.Unlock()
+	}()
+	if !ok || !errors.Is(err, syscall.ECHILD) {
+		return processRusage(cmd.ProcessState), err
+	}
+
+	exit := <-ch
+	if exit.status.Exited() && exit.status.ExitStatus() == 0 {
+		return &exit.rusage, nil
+	}
+	return &exit.rusage, &reapedError{status: exit.status}
+}
+
+// startReaper collects every child that exits while the commands of a run
+// are running, including orphans that were reparented to logexec. Exits of
+// registered children are handed to waitChild, and those of orphans are
+// dropped. The returned function stops the reaper, so that children started
+// between runs are left to their own Wait.
+func startReaper() (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			reapChildren()
+			select {
+			case <-sigchld:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+// reapChildren collects the children that have exited.
+func reapChildren() {
+	for {
+		var ws syscall.WaitStatus
+		var ru syscall.Rusage
+		wpid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, &ru)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || wpid <= 0 {
+			return
+		}
+
+		reapMu.Lock()
+		ch, ok := reaped[wpid]
+		reapMu.Unlock()
+		if ok {
+			ch <- reapedExit{status: ws, rusage: ru}
+		} else {
+			debugf("Reaped orphan %d", wpid)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// +build !linux
+
+package main
+
+func setSubreaper() error {
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+)
+
+const prSetChildSubreaper = 36
+
+// setSubreaper makes orphaned descendants reparent to logexec when it is
+// not already running as PID 1.
+func setSubreaper() error {
+	if os.Getpid() == 1 {
+		return nil
+	}
+	return prctl(prSetChildSubreaper, 1, 0)
+}
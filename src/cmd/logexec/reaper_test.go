@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"logexec"
+)
+
+func TestWaitChild(t *testing.T) {
+	defer func(init bool) { initMode = init }(initMode)
+	initMode = true
+
+	tests := []struct {
+		script string
+		// reaped is whether the reaper collects the exit before waitChild.
+		reaped bool
+		want   int
+	}{
+		{"exit 0", false, 0},
+		{"exit 3", false, 3},
+		{"exit 0", true, 0},
+		{"exit 3", true, 3},
+		{"kill -KILL $$", true, 137},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("sh", "-c", tt.script)
+		if err := startReaped(cmd); err != nil {
+			t.Fatal(err)
+		}
+		pid := cmd.Process.Pid
+		for deadline := time.Now().Add(5 * time.Second); tt.reaped && time.Now().Before(deadline); {
+			reapChildren()
+			reapMu.Lock()
+			n := len(reaped[pid])
+			reapMu.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		rusage, err := waitChild(cmd)
+		reapMu.Lock()
+		_, registered := reaped[pid]
+		reapMu.Unlock()
+		if status := logexec.ExitStatus(err); status != tt.want || rusage == nil || registered {
+			t.Errorf("Error on %q reaped %v, got %v, %v, %v", tt.script, tt.reaped, status, rusage, registered)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"logexec"
+)
+
+var reloadSignal = signalFlag(syscall.SIGHUP)
+
+var (
+	// configMu is held by the writers of the output for each line they
+	// pass through the processors to syslog and the sinks, and by
+	// reloadConfig while it replaces them, so that a reload takes effect
+	// between lines.
+	configMu sync.RWMutex
+
+	// configProcessors is where the processors set by the configuration
+	// file lie in processors, after those given on the command line and
+	// before the fields logexec adds itself.
+	configProcessors struct {
+		start, end int
+	}
+)
+
+func init() {
+	flag.Var(&reloadSignal, "reload-signal",
+		"signal that makes logexec reload the filters, sinks, levels and throttle of -config instead of passing it on, or none")
+}
+
+// reloading reports whether sig tells logexec to reload its configuration
+// file, which it only does when it has one.
+func reloading(sig os.Signal) bool {
+	return configFile != "" && reloadSignal.is(sig)
+}
+
+// reloadableConfig holds the settings a reload replaces.
+type reloadableConfig struct {
+	processors               logexec.Chain
+	sinkURLs                 []string
+	stdoutLevel, stderrLevel logLevel
+	throttle                 int64
+}
+
+// set sets the setting of f to value. Flags that cannot be reloaded are
+// left alone.
+func (rc *reloadableConfig) set(f *flag.Flag, value string) error {
+	switch f.Name {
+	case "drop", "rewrite", "relevel", "enrich":
+		p, err := f.Value.(processorFlag)(value)
+		if err != nil {
+			return err
+		}
+		rc.processors = append(rc.processors, p)
+	case "sink":
+		rc.sinkURLs = append(rc.sinkURLs, value)
+	case "stdoutLevel":
+		return rc.stdoutLevel.Set(value)
+	case "stderrLevel":
+		return rc.stderrLevel.Set(value)
+	case "throttle":
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return err
+		}
+		rc.throttle = n
+	}
+	return nil
+}
+
+// readReloadable reads the settings a reload replaces from the
+// configuration file at path. Settings given on the command line or in the
+// environment are kept, and the others are those of the file, or their
+// defaults if it no longer has them.
+func readReloadable(path string) (*reloadableConfig, error) {
+	entries, err := configEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	set := flagsSet()
+	set["command"] = true
+	rc := &reloadableConfig{
+		stdoutLevel: stdoutLevel,
+		stderrLevel: stderrLevel,
+		throttle:    atomic.LoadInt64(&throttleRate),
+	}
+	if set["sink"] {
+		rc.sinkURLs = sinkURLs
+	}
+	for _, name := range []string{"stdoutLevel", "stderrLevel", "throttle"} {
+		if f := flag.Lookup(name); !set[name] {
+			rc.set(f, f.DefValue)
+		}
+	}
+	for _, e := range entries {
+		if err := applyConfigEntry(e, set, rc.set); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return rc, nil
+}
+
+// reloadConfig reads -config again and replaces the processors it sets,
+// the sinks, the levels of stdout and stderr and the throttle, between two
+// lines of output. Other options only take effect when logexec is started
+// again. Sinks whose URL is unchanged are kept open. If the file cannot be
+// read, has an invalid value or names a sink that cannot be opened, the
+// configuration in use is kept.
+func reloadConfig() {
+	rc, err := readReloadable(configFile)
+	var reloaded, opened []*lockedSink
+	old := map[string]*lockedSink{}
+	for _, s := range sinks {
+		old[s.url] = s
+	}
+	for i := 0; err == nil && i < len(rc.sinkURLs); i++ {
+		u := rc.sinkURLs[i]
+		if s, ok := old[u]; ok {
+			reloaded = append(reloaded, s)
+			delete(old, u)
+			continue
+		}
+		var sink logexec.Sink
+		if sink, err = openSink(u); err != nil {
+			break
+		}
+		s := &lockedSink{url: u, sink: sink}
+		reloaded = append(reloaded, s)
+		opened = append(opened, s)
+		if _, ok := sink.(logexec.MultiLineSink); rawRelay && !ok {
+			err = fmt.Errorf("sink %s cannot take the messages of several lines of -raw", u)
+		}
+	}
+	if err != nil {
+		for _, s := range opened {
+			s.sink.Close()
+		}
+		errorf("Error reloading configuration, keeping the current one: %v", err)
+		return
+	}
+
+	configMu.Lock()
+	chain := append(logexec.Chain(nil), processors[:configProcessors.start]...)
+	chain = append(chain, rc.processors...)
+	processors = append(chain, processors[configProcessors.end:]...)
+	configProcessors.end = configProcessors.start + len(rc.processors)
+	sinks = reloaded
+	stdoutLevel, stderrLevel = rc.stdoutLevel, rc.stderrLevel
+	atomic.StoreInt64(&throttleRate, rc.throttle)
+	configMu.Unlock()
+
+	for _, s := range old {
+		s.mu.Lock()
+		s.sink.Flush()
+		if err := s.sink.Close(); err != nil {
+			warnf("Error closing sink %s: %v", s.url, err)
+		}
+		s.mu.Unlock()
+	}
+	eventf("Configuration reloaded: file=%s processors=%d sinks=%d stdout_level=%v stderr_level=%v throttle=%d",
+		configFile, len(rc.processors), len(reloaded), rc.stdoutLevel, rc.stderrLevel, rc.throttle)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"logexec"
+)
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(f string, p logexec.Chain, s []*lockedSink, out, errl logLevel, th int64) {
+		configFile, processors, sinks, stdoutLevel, stderrLevel, throttleRate = f, p, s, out, errl, th
+		configProcessors.start, configProcessors.end = 0, 0
+	}(configFile, processors, sinks, stdoutLevel, stderrLevel, throttleRate)
+
+	configFile = filepath.Join(dir, "logexec.conf")
+	sink := "file://" + filepath.Join(dir, "out.log")
+	// The processors given on the command line come before those of the
+	// file, and those logexec adds itself after.
+	processors = logexec.Chain{logexec.Enrich("cli", "1"), logexec.Enrich("run_id", "x")}
+	configProcessors.start, configProcessors.end = 1, 1
+	sinks = nil
+	defer func() {
+		for _, s := range sinks {
+			s.sink.Close()
+		}
+	}()
+
+	info, warning, errLevel := logLevel(syslog.LOG_INFO), logLevel(syslog.LOG_WARNING), logLevel(syslog.LOG_ERR)
+	tests := []struct {
+		config   string
+		line     string
+		sinks    []string
+		stdout   logLevel
+		throttle int64
+	}{
+		{`drop = "^keep"`, "", nil, info, 0},
+		{`rewrite = ["/me/you/", "/you/them/"]` + "\nstdoutLevel = err\nthrottle = 1000\nsink = \"" + sink + "\"",
+			"keep them cli=1 run_id=x", []string{sink}, errLevel, 1000},
+		{`drop = "("`, "keep them cli=1 run_id=x", []string{sink}, errLevel, 1000},
+		{"nothing = 1", "keep them cli=1 run_id=x", []string{sink}, errLevel, 1000},
+		{"sink = \"file://" + filepath.Join(dir, "missing", "out.log") + "\"", "keep them cli=1 run_id=x", []string{sink}, errLevel, 1000},
+		{"[other]\nenrich = \"a=b\"\n", "keep me cli=1 run_id=x", nil, info, 0},
+		{"tag = other\ncommand = [\"true\"]\nenrich = \"a=b\"", "keep me cli=1 a=b run_id=x", nil, info, 0},
+	}
+	for _, tt := range tests {
+		if err := ioutil.WriteFile(configFile, []byte(tt.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		reloadConfig()
+
+		r := logexec.Record{Line: []byte("keep me")}
+		line := ""
+		if processors.Process(&r) {
+			line = string(r.Line)
+		}
+		var urls []string
+		for _, s := range sinks {
+			urls = append(urls, s.url)
+		}
+		if line != tt.line || !reflect.DeepEqual(urls, tt.sinks) || stdoutLevel != tt.stdout ||
+			stderrLevel != warning || atomic.LoadInt64(&throttleRate) != tt.throttle {
+			t.Errorf("Error on %q, got %q, %v, %v, %v, %v", tt.config, line, urls, stdoutLevel, stderrLevel, throttleRate)
+		}
+	}
+	if configCommand != nil {
+		t.Errorf("Error on command, got %q", configCommand)
+	}
+}
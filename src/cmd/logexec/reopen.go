@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"log/syslog"
+	"os"
+	"syscall"
+)
+
+// signalFlag is a single signal, or "none" for no signal.
+type signalFlag syscall.Signal
+
+func (s signalFlag) String() string {
+	if s == 0 {
+		return "none"
+	}
+	return signalName(syscall.Signal(s))
+}
+
+func (s *signalFlag) Set(to string) error {
+	if to == "none" || to == "" {
+		*s = 0
+		return nil
+	}
+	sig, err := parseSignal(to)
+	if err != nil {
+		return err
+	}
+	*s = signalFlag(sig)
+	return nil
+}
+
+func (s signalFlag) is(sig os.Signal) bool {
+	return s != 0 && sig == syscall.Signal(s)
+}
+
+var reopenSignal = signalFlag(syscall.SIGUSR1)
+
+func init() {
+	flag.Var(&reopenSignal, "reopen-signal",
+		"signal that makes logexec reconnect to syslog instead of passing it on, or none")
+}
+
+// reopenLogs closes every syslog writer so that each one reconnects on its
+// next write, picking up a restarted syslog daemon, and reopens the sinks.
+// The child is not affected.
+func reopenLogs(children []*child) {
+	writers := []*syslog.Writer{stdoutLog, stderrLog, selfSyslog}
+	for _, c := range children {
+		writers = append(writers, c.stdout.Writer, c.stderr.Writer)
+		for _, sw := range c.streams {
+			writers = append(writers, sw.Writer)
+		}
+	}
+	for _, w := range writers {
+		w.Close()
+	}
+	reopenSinks()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const rlimInfinity = math.MaxUint64
+
+var errInvalidRlimit = errors.New("invalid resource limit, want name=soft[:hard]")
+
+var rlimitByName = map[string]int{
+	"as":     syscall.RLIMIT_AS,
+	"core":   syscall.RLIMIT_CORE,
+	"cpu":    syscall.RLIMIT_CPU,
+	"data":   syscall.RLIMIT_DATA,
+	"fsize":  syscall.RLIMIT_FSIZE,
+	"nofile": syscall.RLIMIT_NOFILE,
+	"stack":  syscall.RLIMIT_STACK,
+}
+
+type rlimit64 struct {
+	Cur uint64
+	Max uint64
+}
+
+// rlimitFlag collects repeated -rlimit name=soft[:hard] settings.
+type rlimitFlag map[string]rlimit64
+
+func formatRlimitValue(v uint64) string {
+	if v == rlimInfinity {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+func parseRlimitValue(s string) (uint64, error) {
+	if s == "unlimited" || s == "infinity" {
+		return rlimInfinity, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func (r rlimitFlag) String() string {
+	var parts []string
+	for name, lim := range r {
+		parts = append(parts, fmt.Sprintf("%s=%s:%s", name,
+			formatRlimitValue(lim.Cur), formatRlimitValue(lim.Max)))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (r rlimitFlag) Set(to string) error {
+	kv := strings.SplitN(to, "=", 2)
+	if len(kv) != 2 {
+		return errInvalidRlimit
+	}
+	if _, ok := rlimitByName[kv[0]]; !ok {
+		return fmt.Errorf("unknown resource limit %q", kv[0])
+	}
+
+	values := strings.SplitN(kv[1], ":", 2)
+	soft, err := parseRlimitValue(values[0])
+	if err != nil {
+		return errInvalidRlimit
+	}
+	hard := soft
+	if len(values) == 2 {
+		hard, err = parseRlimitValue(values[1])
+		if err != nil {
+			return errInvalidRlimit
+		}
+	}
+	if soft > hard {
+		return fmt.Errorf("soft limit for %v exceeds hard limit", kv[0])
+	}
+
+	r[kv[0]] = rlimit64{Cur: soft, Max: hard}
+	return nil
+}
+
+var rlimits = rlimitFlag{}
+
+func init() {
+	flag.Var(rlimits, "rlimit",
+		"resource limit for the child as name=soft[:hard] (repeatable)")
+}
+
+// trampolineLimits returns the -rlimit settings, which are set by the
+// trampoline of each child before the command is exec'd.
+func trampolineLimits() []trampolineLimit {
+	var limits []trampolineLimit
+	for name, lim := range rlimits {
+		limits = append(limits, trampolineLimit{Resource: rlimitByName[name], Cur: lim.Cur, Max: lim.Max})
+	}
+	return limits
+}
+
+// setLimits sets limits on logexec running as a trampoline.
+func setLimits(limits []trampolineLimit) error {
+	for _, l := range limits {
+		err := syscall.Setrlimit(l.Resource, &syscall.Rlimit{Cur: l.Cur, Max: l.Max})
+		if err != nil {
+			return fmt.Errorf("setting resource limit %d: %v", l.Resource, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRlimitFlag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want rlimit64
+	}{
+		{"nofile=1024", rlimit64{1024, 1024}},
+		{"nofile=1024:4096", rlimit64{1024, 4096}},
+		{"core=0:unlimited", rlimit64{0, rlimInfinity}},
+	}
+	for _, tt := range tests {
+		r := rlimitFlag{}
+		if err := r.Set(tt.in); err != nil {
+			t.Errorf("Error on %v: %v", tt.in, err)
+			continue
+		}
+		for _, got := range r {
+			if got != tt.want {
+				t.Errorf("Error on %v, got %v", tt.in, got)
+			}
+		}
+	}
+
+	for _, in := range []string{"nofile", "bogus=1", "nofile=x", "nofile=10:5"} {
+		r := rlimitFlag{}
+		if err := r.Set(in); err == nil {
+			t.Errorf("Expected error on %v", in)
+		}
+	}
+}
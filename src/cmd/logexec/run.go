@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+var errInvalidRun = errors.New("invalid command, want name:cmd [args]")
+var errUnterminatedQuote = errors.New("unterminated quote")
+
+// runSpec is a named command given with -run. Its output is logged under
+// its name, unless stdoutTag or stderrTag is set for a stream.
+type runSpec struct {
+	name                 string
+	args                 []string
+	stdoutTag, stderrTag string
+
+	// main is set on the command that gets the sockets of systemd socket
+	// activation and the -fd streams.
+	main bool
+}
+
+// tags returns the tags that stdout and stderr of s are logged under.
+func (s runSpec) tags() (string, string) {
+	stdout, stderr := s.name, s.name
+	if s.stdoutTag != "" {
+		stdout = s.stdoutTag
+	}
+	if s.stderrTag != "" {
+		stderr = s.stderrTag
+	}
+	return stdout, stderr
+}
+
+// runList collects repeated -run name:cmd [args] commands.
+type runList []runSpec
+
+func (r runList) String() string {
+	var parts []string
+	for _, spec := range r {
+		parts = append(parts, spec.name+":"+strings.Join(spec.args, " "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *runList) Set(to string) error {
+	kv := strings.SplitN(to, ":", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return errInvalidRun
+	}
+	args, err := splitArgs(kv[1])
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errInvalidRun
+	}
+	*r = append(*r, runSpec{name: kv[0], args: args})
+	return nil
+}
+
+// splitArgs splits s into words on whitespace, honouring single and double
+// quotes and backslash escapes the way a shell would.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		word    []rune
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			word = append(word, r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word = append(word, r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, string(word))
+				word = word[:0]
+				inWord = false
+			}
+		default:
+			word = append(word, r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errUnterminatedQuote
+	}
+	if inWord {
+		args = append(args, string(word))
+	}
+	return args, nil
+}
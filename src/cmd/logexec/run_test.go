@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"echo hi", []string{"echo", "hi"}},
+		{"  echo   hi  ", []string{"echo", "hi"}},
+		{`sh -c 'echo "a b"'`, []string{"sh", "-c", `echo "a b"`}},
+		{`echo "it's" a\ b`, []string{"echo", "it's", "a b"}},
+		{`echo ''`, []string{"echo", ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.in)
+		if err != nil {
+			t.Errorf("Error on %v: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Error on %v, got %q", tt.in, got)
+		}
+	}
+
+	for _, in := range []string{`echo 'a`, `echo "a`, `echo a\`} {
+		if _, err := splitArgs(in); err == nil {
+			t.Errorf("Expected error on %v", in)
+		}
+	}
+}
+
+func TestRunList(t *testing.T) {
+	var r runList
+	if err := r.Set("web:nginx -g 'daemon off;'"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	want := runSpec{name: "web", args: []string{"nginx", "-g", "daemon off;"}}
+	if len(r) != 1 || !reflect.DeepEqual(r[0], want) {
+		t.Errorf("Error on run, got %v", r)
+	}
+
+	for _, in := range []string{"nocolon", ":cmd", "name:", "name:  "} {
+		if err := r.Set(in); err == nil {
+			t.Errorf("Expected error on %v", in)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"os"
+
+	"logexec"
+)
+
+// runIDAuto is the -run-id value that generates a new ID.
+const runIDAuto = "auto"
+
+var (
+	runIDFlag string
+
+	// runID is the ID in use, once setupRunID has run.
+	runID string
+)
+
+func init() {
+	flag.StringVar(&runIDFlag, "run-id", "",
+		"append run_id=ID to every line, marker and notification; auto generates a UUID, and the commands get it as LOGEXEC_RUN_ID")
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// setupRunID generates the run ID if asked to, adds it to every line, and
+// puts it in the environment of the commands and hooks. A logexec run by
+// the commands takes it from there, so that its output is grouped with
+// theirs.
+func setupRunID() {
+	id := runIDFlag
+	if id == "" {
+		return
+	}
+	if id == runIDAuto {
+		var err error
+		if id, err = newUUID(); err != nil {
+			fatalf("Error generating run ID: %v", err)
+		}
+	}
+	runID = id
+	processors = append(processors, logexec.Enrich("run_id", fieldValue(runID)))
+	os.Setenv(envName("run-id"), runID)
+}
+
+// runIDField returns the run_id field to append to a message, or "".
+func runIDField() string {
+	if runID == "" {
+		return ""
+	}
+	return " run_id=" + fieldValue(runID)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewUUID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id, err := newUUID()
+		if err != nil || !re.MatchString(id) || seen[id] {
+			t.Errorf("Error on UUID %d, got %q, %v", i, id, err)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processRusage returns the resource usage of an exited process, if known.
+func processRusage(ps *os.ProcessState) *syscall.Rusage {
+	if ps == nil {
+		return nil
+	}
+	ru, _ := ps.SysUsage().(*syscall.Rusage)
+	return ru
+}
+
+// rusageSummary describes the combined resource usage of children: the
+// largest maximum RSS and the total CPU time and major page faults.
+func rusageSummary(children []*child) string {
+	var maxRSS, majflt int64
+	var user, sys time.Duration
+	for _, c := range children {
+		ru := c.rusage
+		if ru == nil {
+			continue
+		}
+		rss := int64(ru.Maxrss)
+		if runtime.GOOS != "darwin" {
+			// Everywhere but on macOS, ru_maxrss is in kilobytes.
+			rss *= 1024
+		}
+		if rss > maxRSS {
+			maxRSS = rss
+		}
+		user += time.Duration(ru.Utime.Nano())
+		sys += time.Duration(ru.Stime.Nano())
+		majflt += int64(ru.Majflt)
+	}
+	return fmt.Sprintf("max_rss_kb=%d user_cpu=%v sys_cpu=%v major_faults=%d",
+		maxRSS/1024, user.Round(time.Millisecond), sys.Round(time.Millisecond), majflt)
+}
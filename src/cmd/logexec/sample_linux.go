@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTick is the unit of the CPU times in /proc, USER_HZ, which is 100
+// on every Linux architecture.
+const clockTick = 10 * time.Millisecond
+
+var (
+	sampleInterval time.Duration
+
+	// lastSamples holds the previous sample of each pid, to work out the
+	// CPU usage in between.
+	lastSamples = map[int]procSample{}
+)
+
+var errInvalidProcStat = errors.New("invalid /proc stat")
+
+func init() {
+	flag.DurationVar(&sampleInterval, "sample", 0,
+		"log the child's CPU and memory use from /proc at this interval (e.g. 30s)")
+}
+
+type procSample struct {
+	at      time.Time
+	cpu     time.Duration
+	rss     int64
+	threads int
+}
+
+// parseProcStat reads the CPU time, resident set size and thread count
+// from the contents of /proc/<pid>/stat.
+func parseProcStat(stat string) (procSample, error) {
+	// The command name may contain spaces and parentheses, so the fields
+	// are counted from the last closing parenthesis, starting at field 3.
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return procSample{}, errInvalidProcStat
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 22 {
+		return procSample{}, errInvalidProcStat
+	}
+	field := func(n int) int64 {
+		v, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return v
+	}
+	return procSample{
+		cpu:     time.Duration(field(14)+field(15)) * clockTick,
+		rss:     field(24) * int64(os.Getpagesize()),
+		threads: int(field(20)),
+	}, nil
+}
+
+// startSampler returns a channel that ticks at the sample interval, or nil
+// if sampling is disabled.
+func startSampler() <-chan time.Time {
+	if sampleInterval <= 0 {
+		return nil
+	}
+	lastSamples = map[int]procSample{}
+	return time.NewTicker(sampleInterval).C
+}
+
+// logSamples logs the resource use of each running child.
+func logSamples(children []*child) {
+	for _, c := range children {
+		pid := c.cmd.Process.Pid
+		b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			// The child has exited.
+			delete(lastSamples, pid)
+			continue
+		}
+		s, err := parseProcStat(string(b))
+		if err != nil {
+			debugf("Sampling pid %d: %v", pid, err)
+			continue
+		}
+		s.at = time.Now()
+
+		last, ok := lastSamples[pid]
+		if !ok {
+			last = procSample{at: c.start}
+		}
+		cpuPercent := 100 * float64(s.cpu-last.cpu) / float64(s.at.Sub(last.at))
+		lastSamples[pid] = s
+		fmt.Fprintf(c.stdout, "Resource sample: pid=%d cpu_percent=%.1f cpu_time=%v rss_kb=%d threads=%d",
+			pid, cpuPercent, s.cpu, s.rss/1024, s.threads)
+	}
+}
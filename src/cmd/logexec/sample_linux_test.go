@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	page := int64(os.Getpagesize())
+	tests := []struct {
+		stat string
+		want procSample
+		ok   bool
+	}{
+		{"1234 (sleep) S 1 1234 1234 0 -1 4194304 90 0 0 0 150 50 0 0 20 0 3 0 100 5685248 200 18446744073709551615",
+			procSample{cpu: 2 * time.Second, rss: 200 * page, threads: 3}, true},
+		{"42 (a (b) c) R 1 42 42 0 -1 0 0 0 0 0 1 2 0 0 20 0 1 0 100 0 10 0",
+			procSample{cpu: 30 * time.Millisecond, rss: 10 * page, threads: 1}, true},
+		{"42 (truncated) R 1 42", procSample{}, false},
+		{"garbage", procSample{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseProcStat(tt.stat)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Error on %v, got %+v, %v", tt.stat, got, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"syscall"
+	"time"
+)
+
+var (
+	every  time.Duration
+	jitter time.Duration
+)
+
+func init() {
+	flag.DurationVar(&every, "every", 0,
+		"run the command repeatedly at this interval (e.g. 10m)")
+	flag.DurationVar(&jitter, "jitter", 0,
+		"random delay of up to this long added to each scheduled run")
+}
+
+// runEvery runs the commands at every interval until ctx is done, when
+// logexec is told to stop. Runs never overlap: slots that pass while a run is still going
+// are skipped and logged.
+func runEvery(ctx context.Context, sigs <-chan os.Signal, specs []runSpec) {
+	next := time.Now()
+	for {
+		start := next
+		if jitter > 0 {
+			start = start.Add(time.Duration(rand.Int63n(int64(jitter))))
+		}
+		if !sleepUntil(ctx, sigs, start) {
+			return
+		}
+
+		status := runOnce(ctx, sigs, specs)
+		if ctx.Err() != nil {
+			return
+		}
+
+		next = next.Add(every)
+		skipped := 0
+		for !next.After(time.Now()) {
+			next = next.Add(every)
+			skipped++
+		}
+		if skipped > 0 {
+			fmt.Fprintf(stderrLog, "Run took longer than %v, skipped %d scheduled runs", every, skipped)
+		}
+		fmt.Fprintf(stdoutLog, "Run exited with status %d, next run at %v%s",
+			status, next.Format(time.RFC3339), runIDField())
+	}
+}
+
+// sleepUntil waits until t, returning false if ctx is done or logexec is
+// told to stop by SIGINT or SIGTERM in the meantime. Other signals caught
+// on sigs are ignored while no command is running.
+func sleepUntil(ctx context.Context, sigs <-chan os.Signal, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		case sig := <-sigs:
+			switch {
+			case dumpSignal.is(sig):
+				logSignal(sig, "dump", nil, nil)
+				dumpState(nil)
+			case reopenSignal.is(sig):
+				logSignal(sig, "reopen", nil, nil)
+				reopenLogs(nil)
+			case reloading(sig):
+				logSignal(sig, "reload", nil, nil)
+				reloadConfig()
+			case ignoredSignal(sig):
+				logSignal(sig, "suppressed", nil, nil)
+			case sig == syscall.SIGINT || sig == syscall.SIGTERM:
+				logSignal(sig, "stop", nil, nil)
+				return false
+			default:
+				logSignal(sig, "ignored", nil, nil)
+			}
+		}
+	}
+}
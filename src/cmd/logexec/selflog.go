@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/syslog"
+	"os"
+)
+
+// selfLogTarget is where logexec logs its own errors and diagnostics.
+type selfLogTarget int
+
+const (
+	selfLogBoth selfLogTarget = iota
+	selfLogStderr
+	selfLogSyslog
+)
+
+var selfLogNames = map[string]selfLogTarget{
+	"both":   selfLogBoth,
+	"stderr": selfLogStderr,
+	"syslog": selfLogSyslog,
+}
+
+var errInvalidSelfLog = errors.New("invalid self log target, must be stderr, syslog or both")
+
+func (t selfLogTarget) String() string {
+	for name, v := range selfLogNames {
+		if v == t {
+			return name
+		}
+	}
+	return ""
+}
+
+func (t *selfLogTarget) Set(s string) error {
+	v, ok := selfLogNames[s]
+	if !ok {
+		return errInvalidSelfLog
+	}
+	*t = v
+	return nil
+}
+
+var (
+	selfLog = selfLogBoth
+	quiet   bool
+
+	// selfSyslog is the syslog writer of logexec's own messages, under
+	// -self-tag and -self-facility.
+	selfSyslog   *syslog.Writer
+	selfTag      string
+	selfFacility streamFacility
+)
+
+func init() {
+	flag.Var(&selfLog, "self-log",
+		"where logexec logs its own errors and diagnostics: stderr, syslog or both")
+	flag.BoolVar(&quiet, "quiet", false,
+		"Do not print notices such as state dumps on stderr; they are still logged to syslog")
+	flag.StringVar(&selfTag, "self-tag", "logexec", "tag for logexec's own messages")
+	flag.Var(&selfFacility, "self-facility", "logging facility for logexec's own messages, if different from -facility")
+}
+
+// openSelfLog opens the syslog writer of logexec's own messages.
+func openSelfLog() error {
+	w, err := UnixSyslog(syslog.LOG_WARNING|selfFacility.priority(), selfTag)
+	if err != nil {
+		return fmt.Errorf("initializing logexec syslog: %v", err)
+	}
+	selfSyslog = w
+	return nil
+}
+
+// selfLogf logs a message about logexec itself at priority, under -self-tag
+// rather than the tags of the commands. Messages go to stderr when syslog is
+// not open yet or cannot be written to, except for notices with -quiet.
+func selfLogf(priority syslog.Priority, format string, v ...interface{}) {
+	logSelf(priority, selfLog != selfLogSyslog, format, v...)
+}
+
+// eventf logs an event, such as how a signal was handled, as a notice. It
+// is meant for the central log rather than the terminal, so it only goes to
+// stderr when syslog is not open yet or cannot be written to, or with
+// -self-log=stderr.
+func eventf(format string, v ...interface{}) {
+	logSelf(syslog.LOG_NOTICE, selfLog == selfLogStderr, format, v...)
+}
+
+func logSelf(priority syslog.Priority, toStderr bool, format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...) + runIDField()
+	toStderr = toStderr || selfSyslog == nil
+	if quiet && priority == syslog.LOG_NOTICE {
+		toStderr = false
+	}
+	if selfLog != selfLogStderr && selfSyslog != nil {
+		var err error
+		switch priority {
+		case syslog.LOG_ERR:
+			err = selfSyslog.Err(msg)
+		case syslog.LOG_NOTICE:
+			err = selfSyslog.Notice(msg)
+		case syslog.LOG_DEBUG:
+			err = selfSyslog.Debug(msg)
+		default:
+			err = selfSyslog.Warning(msg)
+		}
+		toStderr = toStderr || err != nil
+	}
+	if toStderr {
+		log.Print(msg)
+	}
+}
+
+func errorf(format string, v ...interface{}) {
+	selfLogf(syslog.LOG_ERR, format, v...)
+}
+
+func warnf(format string, v ...interface{}) {
+	selfLogf(syslog.LOG_WARNING, format, v...)
+}
+
+func debugf(format string, v ...interface{}) {
+	if debug {
+		selfLogf(syslog.LOG_DEBUG, "debug: "+format, v...)
+	}
+}
+
+// fatalf logs an error about logexec itself and exits with status 1.
+func fatalf(format string, v ...interface{}) {
+	errorf(format, v...)
+	os.Exit(1)
+}
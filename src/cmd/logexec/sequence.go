@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	sequenceLines      bool
+	checkpointInterval time.Duration
+
+	// seqs holds the sequences by tag.stream.
+	seqsMu sync.Mutex
+	seqs   = map[string]*streamSeq{}
+)
+
+func init() {
+	flag.BoolVar(&sequenceLines, "sequence", false,
+		"append seq=N to every line, counting the lines of each tag and stream")
+	flag.DurationVar(&checkpointInterval, "checkpoint", 0,
+		"log the sequence number and checksum of each stream at this interval, implies -sequence (e.g. 1m)")
+}
+
+// sequencing reports whether lines are numbered.
+func sequencing() bool {
+	return sequenceLines || checkpointInterval > 0
+}
+
+// streamSeq numbers the lines of one tag and stream, across runs and the
+// commands that share them, and keeps the CRC-32 of every line numbered so
+// far for the checkpoints.
+type streamSeq struct {
+	mu       sync.Mutex
+	seq      int64
+	checksum uint32
+	// lines is the number of lines since the last checkpoint, and rw the
+	// writer of the last of them, which the checkpoint is written to.
+	lines int64
+	rw    *recordWriter
+}
+
+// streamSequence returns the sequence for tag and stream.
+func streamSequence(tag, stream string) *streamSeq {
+	seqsMu.Lock()
+	defer seqsMu.Unlock()
+	key := tag + "." + stream
+	s, ok := seqs[key]
+	if !ok {
+		s = &streamSeq{}
+		seqs[key] = s
+	}
+	return s
+}
+
+// next numbers line, written by rw, and returns it with its number. It is
+// called with s.mu held.
+func (s *streamSeq) next(rw *recordWriter, line []byte) []byte {
+	s.seq++
+	l := append(line[:len(line):len(line)], " seq="...)
+	l = strconv.AppendInt(l, s.seq, 10)
+	s.checksum = crc32.Update(s.checksum, crc32.IEEETable, l)
+	s.checksum = crc32.Update(s.checksum, crc32.IEEETable, []byte{'\n'})
+	s.lines++
+	s.rw = rw
+	return l
+}
+
+// checkpointMessage is what a checkpoint of stream logs.
+func (s *streamSeq) checkpointMessage(stream string) string {
+	return fmt.Sprintf("Checkpoint: stream=%s seq=%d lines=%d checksum=%08x",
+		stream, s.seq, s.lines, s.checksum)
+}
+
+// startCheckpoints returns a channel that ticks at the -checkpoint
+// interval, or nil if checkpoints are disabled.
+func startCheckpoints() <-chan time.Time {
+	if checkpointInterval <= 0 {
+		return nil
+	}
+	return time.NewTicker(checkpointInterval).C
+}
+
+// writeCheckpoints logs a checkpoint in every stream that has had lines
+// since its last one, with the tag, stream and priority of those lines.
+func writeCheckpoints() {
+	if checkpointInterval <= 0 {
+		return
+	}
+	seqsMu.Lock()
+	defer seqsMu.Unlock()
+	for _, s := range seqs {
+		s.mu.Lock()
+		if s.lines > 0 {
+			r := s.rw.record
+			r.Time = time.Now()
+			r.Line = []byte(s.checkpointMessage(r.Stream))
+			s.rw.emit(r)
+			s.lines = 0
+		}
+		s.mu.Unlock()
+	}
+}
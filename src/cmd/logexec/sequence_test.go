@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStreamSeq(t *testing.T) {
+	var s streamSeq
+	line := []byte("a spare")
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"a", "a seq=1"},
+		{"", " seq=2"},
+		{"c", "c seq=3"},
+	}
+	for _, tt := range tests {
+		if got := string(s.next(nil, []byte(tt.line))); got != tt.want {
+			t.Errorf("Error on %q, got %q", tt.line, got)
+		}
+	}
+	// The number is not appended in place of what follows the line.
+	s.next(nil, line[:1])
+	if string(line) != "a spare" {
+		t.Errorf("Error on shared line, got %q", line)
+	}
+	// The checksum is the CRC-32 of the numbered lines, each with a
+	// newline, as a consumer would compute it.
+	if got, want := s.checkpointMessage("stdout"), "Checkpoint: stream=stdout seq=4 lines=4 checksum=aa56ee39"; got != want {
+		t.Errorf("Error on checkpoint, got %q", got)
+	}
+}
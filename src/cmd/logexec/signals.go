@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var errInvalidSignal = errors.New("invalid signal")
+
+var signalByName = map[string]syscall.Signal{
+	"HUP":    syscall.SIGHUP,
+	"INT":    syscall.SIGINT,
+	"QUIT":   syscall.SIGQUIT,
+	"ILL":    syscall.SIGILL,
+	"TRAP":   syscall.SIGTRAP,
+	"ABRT":   syscall.SIGABRT,
+	"BUS":    syscall.SIGBUS,
+	"FPE":    syscall.SIGFPE,
+	"KILL":   syscall.SIGKILL,
+	"USR1":   syscall.SIGUSR1,
+	"SEGV":   syscall.SIGSEGV,
+	"USR2":   syscall.SIGUSR2,
+	"PIPE":   syscall.SIGPIPE,
+	"ALRM":   syscall.SIGALRM,
+	"TERM":   syscall.SIGTERM,
+	"CHLD":   syscall.SIGCHLD,
+	"CONT":   syscall.SIGCONT,
+	"STOP":   syscall.SIGSTOP,
+	"TSTP":   syscall.SIGTSTP,
+	"TTIN":   syscall.SIGTTIN,
+	"TTOU":   syscall.SIGTTOU,
+	"URG":    syscall.SIGURG,
+	"XCPU":   syscall.SIGXCPU,
+	"XFSZ":   syscall.SIGXFSZ,
+	"VTALRM": syscall.SIGVTALRM,
+	"PROF":   syscall.SIGPROF,
+	"WINCH":  syscall.SIGWINCH,
+	"IO":     syscall.SIGIO,
+	"SYS":    syscall.SIGSYS,
+}
+
+// neverForwarded are signals logexec does not catch: they cannot be
+// caught, are used by the Go runtime or for job control of logexec itself,
+// or report faults in logexec rather than requests for the child.
+var neverForwarded = map[syscall.Signal]bool{
+	syscall.SIGKILL: true,
+	syscall.SIGSTOP: true,
+	syscall.SIGCHLD: true,
+	syscall.SIGURG:  true,
+	syscall.SIGPROF: true,
+	syscall.SIGPIPE: true,
+	syscall.SIGILL:  true,
+	syscall.SIGTRAP: true,
+	syscall.SIGABRT: true,
+	syscall.SIGBUS:  true,
+	syscall.SIGFPE:  true,
+	syscall.SIGSEGV: true,
+	syscall.SIGSYS:  true,
+	syscall.SIGTTIN: true,
+	syscall.SIGTTOU: true,
+}
+
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalByName {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// parseSignal accepts a signal name with or without the SIG prefix, in any
+// case, or a signal number.
+func parseSignal(s string) (syscall.Signal, error) {
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "SIG")
+	if sig, ok := signalByName[name]; ok {
+		return sig, nil
+	}
+	n, err := strconv.Atoi(name)
+	if err != nil || n <= 0 {
+		return 0, errInvalidSignal
+	}
+	return syscall.Signal(n), nil
+}
+
+// signalList is a comma separated list of signals such as "INT,HUP".
+type signalList []syscall.Signal
+
+func (l signalList) String() string {
+	var names []string
+	for _, sig := range l {
+		names = append(names, signalName(sig))
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *signalList) Set(to string) error {
+	var sigs signalList
+	for _, s := range strings.Split(to, ",") {
+		sig, err := parseSignal(s)
+		if err != nil {
+			return err
+		}
+		sigs = append(sigs, sig)
+	}
+	*l = sigs
+	return nil
+}
+
+func (l signalList) contains(sig syscall.Signal) bool {
+	for _, s := range l {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+var errInvalidSignalMap = errors.New("invalid signal mapping, want FROM=TO")
+
+// signalMap translates signals received by logexec into the signals
+// delivered to the child, given as "INT=TERM,HUP=USR1".
+type signalMap map[syscall.Signal]syscall.Signal
+
+func (m signalMap) String() string {
+	var parts []string
+	for from, to := range m {
+		parts = append(parts, signalName(from)+"="+signalName(to))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m signalMap) Set(to string) error {
+	for _, pair := range strings.Split(to, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return errInvalidSignalMap
+		}
+		from, err := parseSignal(kv[0])
+		if err != nil {
+			return err
+		}
+		to, err := parseSignal(kv[1])
+		if err != nil {
+			return err
+		}
+		m[from] = to
+	}
+	return nil
+}
+
+// translate returns the signal to deliver to the child for sig.
+func (m signalMap) translate(sig os.Signal) os.Signal {
+	if s, ok := sig.(syscall.Signal); ok {
+		if to, ok := m[s]; ok {
+			return to
+		}
+	}
+	return sig
+}
+
+var (
+	excludeSignals signalList
+	ignoreSignals  signalList
+	signalMapping  = signalMap{}
+)
+
+func init() {
+	flag.Var(&excludeSignals, "exclude-signals",
+		"signals not to catch and pass on to the child, e.g. USR1,WINCH")
+	flag.Var(&ignoreSignals, "ignore-signals",
+		"signals to catch but not pass on to the child, e.g. INT,HUP")
+	flag.Var(signalMapping, "signal-map",
+		"deliver signals to the child as different signals, e.g. INT=TERM,HUP=USR1")
+}
+
+// ignoredSignal reports whether sig should be swallowed rather than passed
+// on, either because it is listed in -ignore-signals or because -ignoresig
+// ignores them all.
+func ignoredSignal(sig os.Signal) bool {
+	s, ok := sig.(syscall.Signal)
+	return ignoreSig || (ok && ignoreSignals.contains(s))
+}
+
+// forwardedSignals returns every catchable signal that logexec passes on to
+// the child.
+func forwardedSignals() []os.Signal {
+	var nums []int
+	for _, sig := range signalByName {
+		if !neverForwarded[sig] && !excludeSignals.contains(sig) {
+			nums = append(nums, int(sig))
+		}
+	}
+	sort.Ints(nums)
+
+	var sigs []os.Signal
+	for _, n := range nums {
+		sigs = append(sigs, syscall.Signal(n))
+	}
+	return sigs
+}
+
+// stopContext returns a context that is done once logexec is told to stop
+// by SIGINT or SIGTERM. Those signals are still caught with the others to
+// be passed on to the commands, unless they are excluded or suppressed, in
+// which case they do not stop logexec either.
+func stopContext() (context.Context, context.CancelFunc) {
+	var stop []os.Signal
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		if !excludeSignals.contains(sig) && !ignoredSignal(sig) {
+			stop = append(stop, sig)
+		}
+	}
+	if len(stop) == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return signal.NotifyContext(context.Background(), stop...)
+}
+
+// logSignal records how a caught signal was handled as a structured event
+// in syslog, so signal driven restarts and shutdowns can be traced in the
+// central log, and on logexec's own stderr. Action is one of forwarded,
+// suppressed, reopen, stop or ignored; delivered is the signal passed on
+// to children, if any.
+func logSignal(sig os.Signal, action string, delivered os.Signal, children []*child) {
+	msg := fmt.Sprintf("Signal event: signal=%s action=%s",
+		signalName(sig.(syscall.Signal)), action)
+	if delivered != nil {
+		var pids []string
+		for _, c := range children {
+			pids = append(pids, strconv.Itoa(c.cmd.Process.Pid))
+		}
+		msg += fmt.Sprintf(" delivered=%s pids=%s",
+			signalName(delivered.(syscall.Signal)), strings.Join(pids, ","))
+	}
+	eventf("%s", msg)
+}
@@ -0,0 +1,12 @@
+// +build !linux
+
+package main
+
+import (
+	"syscall"
+)
+
+func init() {
+	signalByName["EMT"] = syscall.SIGEMT
+	signalByName["INFO"] = syscall.SIGINFO
+}
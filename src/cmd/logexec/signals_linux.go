@@ -0,0 +1,10 @@
+package main
+
+import (
+	"syscall"
+)
+
+func init() {
+	signalByName["PWR"] = syscall.SIGPWR
+	signalByName["STKFLT"] = syscall.SIGSTKFLT
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want syscall.Signal
+	}{
+		{"HUP", syscall.SIGHUP},
+		{"sigterm", syscall.SIGTERM},
+		{" usr1", syscall.SIGUSR1},
+		{"9", syscall.SIGKILL},
+	}
+	for _, tt := range tests {
+		got, err := parseSignal(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Error on %v, got %v (%v)", tt.in, got, err)
+		}
+	}
+
+	for _, in := range []string{"", "BOGUS", "-1", "0"} {
+		if _, err := parseSignal(in); err == nil {
+			t.Errorf("Expected error on %v", in)
+		}
+	}
+}
+
+func TestForwardedSignals(t *testing.T) {
+	excludeSignals = signalList{syscall.SIGWINCH}
+	defer func() { excludeSignals = nil }()
+
+	found := map[syscall.Signal]bool{}
+	for _, sig := range forwardedSignals() {
+		found[sig.(syscall.Signal)] = true
+	}
+	for _, sig := range []syscall.Signal{syscall.SIGHUP, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGCONT} {
+		if !found[sig] {
+			t.Errorf("Error on %v, not forwarded", sig)
+		}
+	}
+	for _, sig := range []syscall.Signal{syscall.SIGKILL, syscall.SIGCHLD, syscall.SIGURG, syscall.SIGWINCH} {
+		if found[sig] {
+			t.Errorf("Error on %v, forwarded", sig)
+		}
+	}
+}
+
+func TestSignalMap(t *testing.T) {
+	m := signalMap{}
+	if err := m.Set("INT=TERM, sighup=usr1"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if got := m.translate(syscall.SIGINT); got != syscall.SIGTERM {
+		t.Errorf("Error on INT, got %v", got)
+	}
+	if got := m.translate(syscall.SIGHUP); got != syscall.SIGUSR1 {
+		t.Errorf("Error on HUP, got %v", got)
+	}
+	if got := m.translate(syscall.SIGQUIT); got != syscall.SIGQUIT {
+		t.Errorf("Error on QUIT, got %v", got)
+	}
+	if m.String() != "HUP=USR1,INT=TERM" {
+		t.Errorf("Error on String, got %v", m)
+	}
+
+	for _, in := range []string{"INT", "INT=", "BOGUS=TERM"} {
+		if err := (signalMap{}).Set(in); err == nil {
+			t.Errorf("Expected error on %v", in)
+		}
+	}
+}
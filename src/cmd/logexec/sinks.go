@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"logexec"
+)
+
+const sinkFlushInterval = time.Second
+
+// sinkList collects repeated -sink URLs.
+type sinkList []string
+
+func (l sinkList) String() string {
+	return strings.Join(l, ", ")
+}
+
+func (l *sinkList) Set(to string) error {
+	*l = append(*l, to)
+	return nil
+}
+
+var (
+	sinkURLs sinkList
+
+	// sinks are the -sink destinations, opened once and shared by every
+	// run.
+	sinks []*lockedSink
+)
+
+func init() {
+	flag.Var(&sinkURLs, "sink",
+		"additional destination for the command's output, such as file:///var/log/job.log; may be repeated")
+}
+
+// lockedSink serializes the use of a sink by the writers of every stream.
+type lockedSink struct {
+	mu   sync.Mutex
+	url  string
+	sink logexec.Sink
+}
+
+// openSinks opens the -sink destinations.
+func openSinks() {
+	for _, u := range sinkURLs {
+		s, err := openSink(u)
+		if err != nil {
+			fatalf("Error opening sink: %v", err)
+		}
+		sinks = append(sinks, &lockedSink{url: u, sink: s})
+	}
+	checkRawSinks()
+}
+
+// openSink opens the sink for rawurl. The helpers of exec sinks are
+// children of logexec too, so they are started and waited for as the
+// commands are, for the reaper of -init to hand their exits back.
+func openSink(rawurl string) (logexec.Sink, error) {
+	if u, err := url.Parse(rawurl); err == nil && u.Scheme == "exec" {
+		return logexec.OpenExecSink(u, startReaped, waitReaped)
+	}
+	return logexec.OpenSink(rawurl)
+}
+
+// startSinkFlush returns a channel that ticks when the sinks should be
+// flushed, or nil if there are none and a reload of -config cannot add any.
+func startSinkFlush() <-chan time.Time {
+	if len(sinks) == 0 && configFile == "" {
+		return nil
+	}
+	return time.NewTicker(sinkFlushInterval).C
+}
+
+// eachSink calls f with every sink, logging the errors it returns.
+func eachSink(what string, f func(logexec.Sink) error) {
+	for _, s := range sinks {
+		s.mu.Lock()
+		err := f(s.sink)
+		s.mu.Unlock()
+		if err != nil {
+			warnf("Error %s sink %s: %v", what, s.url, err)
+		}
+	}
+}
+
+func flushSinks() {
+	eachSink("flushing", logexec.Sink.Flush)
+}
+
+func reopenSinks() {
+	eachSink("reopening", logexec.Sink.Reopen)
+}
+
+func closeSinks() {
+	eachSink("closing", logexec.Sink.Close)
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	spoolDir      string
+	spoolMax      int64
+	spoolCompress bool
+	spoolKeep     int
+
+	spoolsMu sync.Mutex
+	spools   = map[string]*spool{}
+)
+
+func init() {
+	flag.StringVar(&spoolDir, "spool-dir", "",
+		"directory to spool output to while syslog is unavailable, replayed once it recovers")
+	flag.Int64Var(&spoolMax, "spool-max", 16<<20,
+		"maximum size in bytes of each spool file; lines beyond it are dropped")
+	flag.BoolVar(&spoolCompress, "spool-compress", false,
+		"Compress full spool files into gzip segments and keep spooling, instead of dropping lines")
+	flag.IntVar(&spoolKeep, "spool-keep", 4,
+		"maximum number of compressed segments of each spool with -spool-compress")
+}
+
+// spool is an on-disk queue of lines that could not be written to syslog.
+// Lines are appended at the end and replayed to w from offset onwards.
+// With -spool-compress, a full spool is compressed into a segment, and the
+// segments are replayed before the rest.
+type spool struct {
+	mu       sync.Mutex
+	w        *syslog.Writer
+	f        *os.File
+	size     int64
+	offset   int64
+	dropped  int64
+	retrying bool
+
+	// segments are the paths of the compressed segments, oldest first.
+	// segmentLines is the number of lines of the first one replayed.
+	segments     []string
+	segmentLines int
+}
+
+// spoolWriter writes to syslog, falling back to its spool or else the
+// fallback file when syslog cannot be reached. Without either it is a plain
+// syslog writer.
+type spoolWriter struct {
+	*syslog.Writer
+	tag, stream string
+	spool       *spool
+	failing     int32
+}
+
+// newSpoolWriter wraps w with the spool for tag and stream. Spools are
+// shared by every writer with the same tag and stream, and any lines left
+// over from a previous run are replayed first. If the spool cannot be
+// opened, w is left for the caller to close.
+func newSpoolWriter(w *syslog.Writer, tag, stream string) (*spoolWriter, error) {
+	sw := &spoolWriter{Writer: w, tag: tag, stream: stream}
+	if spoolDir == "" {
+		return sw, nil
+	}
+
+	path := filepath.Join(spoolDir, spoolName(tag, stream))
+	spoolsMu.Lock()
+	defer spoolsMu.Unlock()
+	if s, ok := spools[path]; ok {
+		sw.spool = s
+		return sw, nil
+	}
+
+	// Each spool is used by one logexec at a time, as it is emptied on
+	// replay with no regard for lines appended by another.
+	s, err := openSpool(path, w)
+	if err == syscall.EWOULDBLOCK {
+		return nil, fmt.Errorf("spool %s is in use by another logexec, give each one its own -tag or -spool-dir", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening spool: %v", err)
+	}
+	restoreSpool(path, s)
+	sw.spool = s
+	spools[path] = s
+	if s.size > 0 {
+		s.mu.Lock()
+		s.startRetry()
+		s.mu.Unlock()
+	}
+	return sw, nil
+}
+
+func spoolName(tag, stream string) string {
+	return strings.Replace(tag, "/", "_", -1) + "." + stream + ".spool"
+}
+
+// openSpool opens the spool at path, replaying to w, and locks it without
+// waiting. It fails with EWOULDBLOCK if the spool is in use.
+func openSpool(path string, w *syslog.Writer) (*spool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	segments, err := spoolSegments(path)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &spool{w: w, f: f, size: fi.Size(), segments: segments}, nil
+}
+
+// spoolSegments returns the paths of the compressed segments of the spool
+// at path, oldest first. They are named after the spool with a sequence
+// number, as in job.stdout.spool.3.gz.
+func spoolSegments(path string) ([]string, error) {
+	paths, err := filepath.Glob(path + ".*.gz")
+	if err != nil {
+		return nil, err
+	}
+	var segments []string
+	seqs := map[string]int{}
+	for _, p := range paths {
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(p, path+"."), ".gz"))
+		if err != nil {
+			continue
+		}
+		seqs[p] = seq
+		segments = append(segments, p)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return seqs[segments[i]] < seqs[segments[j]]
+	})
+	return segments, nil
+}
+
+func (w *spoolWriter) Write(b []byte) (int, error) {
+	return w.writeLevel(ownLevel, b)
+}
+
+// writeLevel writes b at level, or at the priority of the writer with
+// ownLevel. Lines that are spooled are replayed at the priority of the
+// writer.
+func (w *spoolWriter) writeLevel(level syslog.Priority, b []byte) (int, error) {
+	s := w.spool
+	if s == nil {
+		if fallbackFile == "" {
+			return len(b), w.write(level, b)
+		}
+		if err := w.write(level, b); err != nil {
+			return len(b), writeFallback(w.tag, w.stream, b, err)
+		}
+		fallbackRecovered(w.Writer)
+		return len(b), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending() {
+		if err := s.replay(); err != nil {
+			return len(b), s.add(b)
+		}
+	}
+	if err := w.write(level, b); err != nil {
+		s.startRetry()
+		return len(b), s.add(b)
+	}
+	return len(b), nil
+}
+
+// Close closes the syslog connection. A writer that is closed while failing
+// no longer counts as failing.
+func (w *spoolWriter) Close() error {
+	if atomic.CompareAndSwapInt32(&w.failing, 1, 0) {
+		atomic.AddInt64(&failingWriters, -1)
+	}
+	return w.Writer.Close()
+}
+
+// write writes b to syslog at level, counting failures and recoveries for
+// the metrics and health checks.
+func (w *spoolWriter) write(level syslog.Priority, b []byte) error {
+	if err := writeSyslog(w.Writer, level, b); err != nil {
+		atomic.AddInt64(&sinkErrors, 1)
+		if atomic.CompareAndSwapInt32(&w.failing, 0, 1) {
+			atomic.AddInt64(&failingWriters, 1)
+			debugf("Writing %s %s to syslog failed: %v", w.tag, w.stream, err)
+		}
+		return err
+	}
+	if atomic.CompareAndSwapInt32(&w.failing, 1, 0) {
+		atomic.AddInt64(&failingWriters, -1)
+		atomic.AddInt64(&reconnects, 1)
+		debugf("Writing %s %s to syslog recovered", w.tag, w.stream)
+	}
+	return nil
+}
+
+// ownLevel stands for the priority a syslog.Writer was opened with.
+const ownLevel syslog.Priority = -1
+
+// writeSyslog writes b to w at level, or at the priority of w with ownLevel.
+func writeSyslog(w *syslog.Writer, level syslog.Priority, b []byte) error {
+	m := string(b)
+	switch level {
+	case ownLevel:
+		_, err := w.Write(b)
+		return err
+	case syslog.LOG_EMERG:
+		return w.Emerg(m)
+	case syslog.LOG_ALERT:
+		return w.Alert(m)
+	case syslog.LOG_CRIT:
+		return w.Crit(m)
+	case syslog.LOG_ERR:
+		return w.Err(m)
+	case syslog.LOG_WARNING:
+		return w.Warning(m)
+	case syslog.LOG_NOTICE:
+		return w.Notice(m)
+	case syslog.LOG_INFO:
+		return w.Info(m)
+	}
+	return w.Debug(m)
+}
+
+func (s *spool) pending() bool {
+	return s.offset < s.size || s.dropped > 0 || len(s.segments) > 0
+}
+
+// add appends b to the spool. If the spool is full it is compressed into a
+// new segment when allowed, and b is dropped otherwise.
+func (s *spool) add(b []byte) error {
+	full := s.size-s.offset+int64(len(b))+1 > spoolMax
+	if full && s.size > s.offset && spoolCompress && len(s.segments) < spoolKeep {
+		if err := s.compress(); err != nil {
+			return err
+		}
+		full = int64(len(b))+1 > spoolMax
+	}
+	if full {
+		if s.dropped == 0 {
+			debugf("Spool %s is full, dropping lines", s.f.Name())
+		}
+		s.dropped++
+		return nil
+	}
+	if s.size == s.offset {
+		debugf("Spooling to %s", s.f.Name())
+	}
+	n, err := s.f.Write(append(b, '\n'))
+	s.size += int64(n)
+	return err
+}
+
+// compress moves the lines not yet replayed into a new compressed segment
+// and empties the spool.
+func (s *spool) compress() error {
+	seq := 1
+	if n := len(s.segments); n > 0 {
+		last := s.segments[n-1]
+		seq, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(last, s.f.Name()+"."), ".gz"))
+		seq++
+	}
+	path := s.f.Name() + "." + strconv.Itoa(seq) + ".gz"
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, io.NewSectionReader(s.f, s.offset, s.size-s.offset))
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = s.f.Truncate(0)
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	debugf("Compressed %d bytes of %s into %s", s.size-s.offset, s.f.Name(), path)
+	s.segments = append(s.segments, path)
+	s.size, s.offset = 0, 0
+	return nil
+}
+
+// replaySegments writes the lines of the compressed segments to syslog in
+// order, removing each segment once it has been replayed.
+func (s *spool) replaySegments() error {
+	for len(s.segments) > 0 {
+		f, err := os.Open(s.segments[0])
+		if err != nil {
+			return err
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		sc := bufio.NewScanner(zr)
+		sc.Buffer(nil, int(spoolMax)+1)
+		for n := 0; sc.Scan(); n++ {
+			if n < s.segmentLines {
+				continue
+			}
+			if _, err := s.w.Write(sc.Bytes()); err != nil {
+				f.Close()
+				return err
+			}
+			s.segmentLines++
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return err
+		}
+		debugf("Replayed %s", s.segments[0])
+		os.Remove(s.segments[0])
+		s.segments, s.segmentLines = s.segments[1:], 0
+	}
+	return nil
+}
+
+// replay writes the spooled lines to syslog in order and empties the spool. On
+// error the lines not yet written are kept for the next attempt.
+func (s *spool) replay() error {
+	if err := s.replaySegments(); err != nil {
+		return err
+	}
+	r := bufio.NewReader(io.NewSectionReader(s.f, s.offset, s.size-s.offset))
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.w.Write(line[:len(line)-1]); err != nil {
+			return err
+		}
+		s.offset += int64(len(line))
+	}
+	if s.dropped > 0 {
+		if _, err := fmt.Fprintf(s.w, "Dropped %d lines while syslog was unavailable", s.dropped); err != nil {
+			return err
+		}
+		s.dropped = 0
+	}
+	if err := s.f.Truncate(0); err != nil {
+		return err
+	}
+	debugf("Replayed %d bytes from %s", s.size, s.f.Name())
+	s.size, s.offset = 0, 0
+	return nil
+}
+
+// startRetry replays the spool in the background until it is empty, so
+// that it is not left waiting for the command's next line.
+func (s *spool) startRetry() {
+	if s.retrying {
+		return
+	}
+	s.retrying = true
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for range t.C {
+			s.mu.Lock()
+			if !s.pending() || s.replay() == nil {
+				s.retrying = false
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// flushSpools makes a last attempt to replay every spool. Anything that
+// still cannot be written stays on disk for the next run.
+func flushSpools() {
+	spoolsMu.Lock()
+	defer spoolsMu.Unlock()
+	for _, s := range spools {
+		s.mu.Lock()
+		if s.pending() {
+			s.replay()
+		}
+		s.mu.Unlock()
+	}
+}
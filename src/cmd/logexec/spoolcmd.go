@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// spoolCommand runs logexec spool ls|cat|replay [name ...] and returns its
+// exit status. Names are spool file names as listed by ls; without any,
+// every spool in -spool-dir is used.
+func spoolCommand(args []string) int {
+	if spoolDir == "" {
+		log.Fatalf("The spool commands need -spool-dir")
+	}
+	names := args[1:]
+	if len(names) == 0 {
+		paths, err := filepath.Glob(filepath.Join(spoolDir, "*.spool"))
+		if err != nil {
+			log.Fatalf("Error listing spools: %v", err)
+		}
+		for _, path := range paths {
+			names = append(names, filepath.Base(path))
+		}
+		sort.Strings(names)
+	}
+
+	switch args[0] {
+	case "ls":
+		return spoolList(names)
+	case "cat":
+		return spoolCat(names)
+	case "replay":
+		return spoolReplay(names)
+	}
+	log.Fatalf("Unknown spool command %q, must be ls, cat or replay", args[0])
+	return 1
+}
+
+// parseSpoolName splits a spool file name into its tag and stream.
+func parseSpoolName(name string) (tag, stream string, ok bool) {
+	base := strings.TrimSuffix(name, ".spool")
+	i := strings.LastIndex(base, ".")
+	if base == name || i < 0 {
+		return "", "", false
+	}
+	tag, stream = base[:i], base[i+1:]
+	return tag, stream, stream == "stdout" || stream == "stderr"
+}
+
+func spoolList(names []string) int {
+	status := 0
+	for _, name := range names {
+		f, err := openSpoolContents(filepath.Join(spoolDir, name))
+		if err != nil {
+			log.Printf("Error opening spool: %v", err)
+			status = 1
+			continue
+		}
+		lines, bytes := 0, 0
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadBytes('\n')
+			if err != nil {
+				break
+			}
+			lines++
+			bytes += len(line) - 1
+		}
+		f.Close()
+		fmt.Printf("%s\t%d lines\t%d bytes\n", name, lines, bytes)
+	}
+	return status
+}
+
+func spoolCat(names []string) int {
+	status := 0
+	for _, name := range names {
+		f, err := openSpoolContents(filepath.Join(spoolDir, name))
+		if err != nil {
+			log.Printf("Error opening spool: %v", err)
+			status = 1
+			continue
+		}
+		if len(names) == 1 {
+			io.Copy(os.Stdout, f)
+		} else {
+			tag, stream, _ := parseSpoolName(name)
+			s := bufio.NewScanner(f)
+			for s.Scan() {
+				fmt.Printf("%s[%s]: %s\n", tag, stream, s.Bytes())
+			}
+		}
+		f.Close()
+	}
+	return status
+}
+
+// spoolContents reads the compressed segments of a spool followed by the
+// spool itself.
+type spoolContents struct {
+	io.Reader
+	files []*os.File
+}
+
+func openSpoolContents(path string) (*spoolContents, error) {
+	segments, err := spoolSegments(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &spoolContents{}
+	var readers []io.Reader
+	for _, p := range append(segments, path) {
+		f, err := os.Open(p)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.files = append(c.files, f)
+		if p == path {
+			readers = append(readers, f)
+			continue
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+		readers = append(readers, zr)
+	}
+	c.Reader = io.MultiReader(readers...)
+	return c, nil
+}
+
+func (c *spoolContents) Close() error {
+	for _, f := range c.files {
+		f.Close()
+	}
+	return nil
+}
+
+// spoolReplay sends each spool to syslog under its tag, at the level of its
+// stream, and empties it. Spools in use by a running logexec are skipped.
+func spoolReplay(names []string) int {
+	// Lines a logexec replayed before it stopped are not sent again.
+	if err := loadSavedSpools(); err != nil {
+		log.Printf("Error loading state file: %v", err)
+		return 1
+	}
+	status := 0
+	for _, name := range names {
+		tag, stream, ok := parseSpoolName(name)
+		if !ok {
+			log.Printf("Not a spool file: %s", name)
+			status = 1
+			continue
+		}
+		stdout, stderr, err := openLogs(tag, tag)
+		if err != nil {
+			log.Printf("Error replaying %s: %v", name, err)
+			status = 1
+			continue
+		}
+		w := stdout
+		if stream == "stderr" {
+			w = stderr
+		}
+
+		path := filepath.Join(spoolDir, name)
+		s, err := openSpool(path, w)
+		if err == nil {
+			restoreSpool(path, s)
+		}
+		if err == syscall.EWOULDBLOCK {
+			log.Printf("Spool %s is in use by a running logexec, skipping", name)
+			status = 1
+		} else if err != nil {
+			log.Printf("Error opening spool: %v", err)
+			status = 1
+		} else if err := s.replay(); err != nil {
+			log.Printf("Error replaying %s: %v", name, err)
+			status = 1
+		} else {
+			log.Printf("Replayed %s", name)
+		}
+		if s != nil {
+			s.f.Close()
+		}
+		stdout.Close()
+		stderr.Close()
+	}
+	return status
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSpoolName(t *testing.T) {
+	tests := []struct {
+		name        string
+		tag, stream string
+		ok          bool
+	}{
+		{"web.stdout.spool", "web", "stdout", true},
+		{"my.app.stderr.spool", "my.app", "stderr", true},
+		{"web.stdin.spool", "web", "stdin", false},
+		{"web.stdout", "", "", false},
+		{"spool", "", "", false},
+	}
+	for _, tt := range tests {
+		tag, stream, ok := parseSpoolName(tt.name)
+		if tag != tt.tag || stream != tt.stream || ok != tt.ok {
+			t.Errorf("Error on %v, got %v %v %v", tt.name, tag, stream, ok)
+		}
+	}
+}
+
+func TestSpoolCompress(t *testing.T) {
+	defer func(max int64, compress bool, keep int) {
+		spoolMax, spoolCompress, spoolKeep = max, compress, keep
+	}(spoolMax, spoolCompress, spoolKeep)
+	spoolMax, spoolCompress, spoolKeep = 10, true, 2
+
+	path := filepath.Join(t.TempDir(), "job.stdout.spool")
+	s, err := openSpool(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"one", "two", "three", "four", "five", "six", "seven"} {
+		if err := s.add([]byte(l)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.segments) != 2 || s.dropped != 2 {
+		t.Errorf("Error on %v, got segments %v, dropped %v", path, s.segments, s.dropped)
+	}
+
+	c, err := openSpoolContents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	b, err := ioutil.ReadAll(c)
+	if want := "one\ntwo\nthree\nfour\nfive\n"; err != nil || string(b) != want {
+		t.Errorf("Error on %v, got %q, %v", path, b, err)
+	}
+
+	s.f.Close()
+	s2, err := openSpool(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.f.Close()
+	if !reflect.DeepEqual(s2.segments, s.segments) {
+		t.Errorf("Error on reopening %v, got segments %v", path, s2.segments)
+	}
+}
+
+// testSyslog listens on a syslog socket in dir and returns a writer to it
+// and a function that returns the next n messages it received, without
+// their headers.
+func testSyslog(t *testing.T, dir string) (*syslog.Writer, *net.UnixConn, func(n int) []string) {
+	path := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := syslog.Dial("unixgram", path, syslog.LOG_INFO|syslog.LOG_LOCAL0, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(n int) []string {
+		var got []string
+		b := make([]byte, 1024)
+		for len(got) < n {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			m, err := conn.Read(b)
+			if err != nil {
+				break
+			}
+			msg := string(b[:m])
+			got = append(got, strings.TrimSuffix(msg[strings.Index(msg, "]: ")+3:], "\n"))
+		}
+		return got
+	}
+	return w, conn, read
+}
+
+func TestSpoolReplay(t *testing.T) {
+	defer func(max int64) { spoolMax = max }(spoolMax)
+	tests := []struct {
+		max   int64
+		lines []string
+		down  bool
+		want  []string
+	}{
+		{100, []string{"a", "b", "c"}, false, []string{"a", "b", "c"}},
+		{4, []string{"a", "b", "c", "d"}, false, []string{"a", "b", "Dropped 2 lines while syslog was unavailable"}},
+		{100, []string{"a", "b"}, true, nil},
+	}
+	for _, tt := range tests {
+		spoolMax = tt.max
+		dir := t.TempDir()
+		w, conn, read := testSyslog(t, dir)
+		path := filepath.Join(dir, "job.stdout.spool")
+		s, err := openSpool(path, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := openSpool(path, w); err != syscall.EWOULDBLOCK {
+			t.Errorf("Error on opening %v twice, got %v", tt.lines, err)
+		}
+		for _, l := range tt.lines {
+			if err := s.add([]byte(l)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		size := s.size
+		if tt.down {
+			conn.Close()
+			os.Remove(filepath.Join(dir, "log"))
+		}
+
+		err = s.replay()
+		if got := read(len(tt.want)); (err != nil) != tt.down || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Error on %v, got %q, %v", tt.lines, got, err)
+		}
+		// A replayed spool is emptied, and one that could not be is kept.
+		fi, _ := s.f.Stat()
+		if tt.down && (s.size != size || fi.Size() != size) || !tt.down && (s.size != 0 || fi.Size() != 0 || s.pending()) {
+			t.Errorf("Error on %v, got size %d, file size %d", tt.lines, s.size, fi.Size())
+		}
+		s.f.Close()
+		conn.Close()
+		w.Close()
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// stateSaveInterval is how often the state file is written while the
+// commands run, besides after each run.
+const stateSaveInterval = time.Minute
+
+var (
+	stateFile string
+
+	// starts is the number of times logexec has started with the state
+	// file, this time included.
+	starts int64
+
+	// savedSpools holds the replay positions of the spools from the state
+	// file, until the spools are opened.
+	savedSpools map[string]spoolState
+)
+
+func init() {
+	flag.StringVar(&stateFile, "state-file", "",
+		"file to keep counters, sequence numbers and spool positions in across restarts; defaults to <tag>.state in -spool-dir")
+}
+
+// persistedState is the content of the state file.
+type persistedState struct {
+	Starts int64 `json:"starts"`
+	Runs   int64 `json:"runs"`
+	// Totals are the statistics of stdout and stderr over all runs.
+	Totals  map[string]streamTotalsState `json:"totals,omitempty"`
+	Streams map[string]streamState       `json:"streams,omitempty"`
+	Spools  map[string]spoolState        `json:"spools,omitempty"`
+}
+
+type streamTotalsState struct {
+	Lines     int64 `json:"lines"`
+	Bytes     int64 `json:"bytes"`
+	Dropped   int64 `json:"dropped"`
+	Truncated int64 `json:"truncated"`
+}
+
+// streamState is the sequence of a tag and stream.
+type streamState struct {
+	Seq      int64  `json:"seq"`
+	Checksum uint32 `json:"checksum"`
+}
+
+// spoolState is how far a spool has been replayed. It only applies to the
+// spool while it has the same size, so that lines are replayed again
+// rather than skipped if the spool has changed in the meantime.
+type spoolState struct {
+	Size         int64  `json:"size"`
+	Offset       int64  `json:"offset"`
+	Segment      string `json:"segment,omitempty"`
+	SegmentLines int    `json:"segment_lines,omitempty"`
+	Dropped      int64  `json:"dropped,omitempty"`
+}
+
+// statePath returns the state file to use, or "" if there is none.
+func statePath() string {
+	if stateFile == "" && spoolDir != "" {
+		return filepath.Join(spoolDir, strings.Replace(tag, "/", "_", -1)+".state")
+	}
+	return stateFile
+}
+
+// loadState restores the state saved by a previous logexec, and saves it
+// again with the start counted. A missing state file is not an error.
+func loadState() error {
+	path := statePath()
+	if path == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var st persistedState
+	if err == nil {
+		if err := json.Unmarshal(b, &st); err != nil {
+			return err
+		}
+	}
+
+	starts = st.Starts + 1
+	atomic.StoreInt64(&runs, st.Runs)
+	for _, s := range outputStreams() {
+		t := st.Totals[s.name]
+		s.past.Lines, s.past.Bytes = t.Lines, t.Bytes
+		s.past.dropped, s.past.Truncated = t.Dropped, t.Truncated
+	}
+	for key, ss := range st.Streams {
+		seqs[key] = &streamSeq{seq: ss.Seq, checksum: ss.Checksum}
+	}
+	savedSpools = st.Spools
+	debugf("Loaded state from %s: starts=%d runs=%d", path, starts, st.Runs)
+	return saveState()
+}
+
+// loadSavedSpools reads the replay positions of the spools from -state-file,
+// or else from every state file in -spool-dir, for the spool replay command,
+// which replays spools without the rest of the state.
+func loadSavedSpools() error {
+	paths := []string{stateFile}
+	if stateFile == "" {
+		var err error
+		if paths, err = filepath.Glob(filepath.Join(spoolDir, "*.state")); err != nil {
+			return err
+		}
+	}
+	savedSpools = map[string]spoolState{}
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var st persistedState
+		if err := json.Unmarshal(b, &st); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for p, ss := range st.Spools {
+			savedSpools[p] = ss
+		}
+	}
+	return nil
+}
+
+// restoreSpool sets the replay position of s from the state file, if it
+// has one for the spool as it is now. It is called before s is shared.
+func restoreSpool(path string, s *spool) {
+	ss, ok := savedSpools[path]
+	if !ok {
+		return
+	}
+	delete(savedSpools, path)
+	if ss.Size != s.size || ss.Offset > s.size {
+		debugf("Spool %s has changed since the state was saved, replaying it all", path)
+		return
+	}
+	s.offset = ss.Offset
+	s.dropped = ss.Dropped
+	if len(s.segments) > 0 && s.segments[0] == ss.Segment {
+		s.segmentLines = ss.SegmentLines
+	}
+}
+
+// saveState atomically replaces the state file with the current state,
+// once loadState has read it.
+func saveState() error {
+	path := statePath()
+	if path == "" || starts == 0 {
+		return nil
+	}
+	st := persistedState{
+		Starts:  starts,
+		Runs:    atomic.LoadInt64(&runs),
+		Totals:  map[string]streamTotalsState{},
+		Streams: map[string]streamState{},
+		Spools:  map[string]spoolState{},
+	}
+	totals := streamTotals()
+	for i, s := range outputStreams() {
+		st.Totals[s.name] = streamTotalsState{
+			Lines:     totals[i].Lines,
+			Bytes:     totals[i].Bytes,
+			Dropped:   totals[i].dropped,
+			Truncated: totals[i].Truncated,
+		}
+	}
+	seqsMu.Lock()
+	for key, s := range seqs {
+		s.mu.Lock()
+		st.Streams[key] = streamState{Seq: s.seq, Checksum: s.checksum}
+		s.mu.Unlock()
+	}
+	seqsMu.Unlock()
+	spoolsMu.Lock()
+	for p, s := range spools {
+		s.mu.Lock()
+		ss := spoolState{Size: s.size, Offset: s.offset, Dropped: s.dropped}
+		if len(s.segments) > 0 {
+			ss.Segment, ss.SegmentLines = s.segments[0], s.segmentLines
+		}
+		s.mu.Unlock()
+		if ss.Offset > 0 || ss.SegmentLines > 0 || ss.Dropped > 0 {
+			st.Spools[p] = ss
+		}
+	}
+	spoolsMu.Unlock()
+
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".logexec-state")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(b, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// startStateSave returns a channel that ticks when the state file should
+// be written, or nil if there is none.
+func startStateSave() <-chan time.Time {
+	if statePath() == "" {
+		return nil
+	}
+	return time.NewTicker(stateSaveInterval).C
+}
+
+// writeState saves the state, logging a failure.
+func writeState() {
+	if err := saveState(); err != nil {
+		errorf("Error writing state file: %v", err)
+	}
+}
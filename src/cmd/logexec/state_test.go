@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreSpool(t *testing.T) {
+	saved := spoolState{Size: 100, Offset: 40, Segment: "a.spool.1.gz", SegmentLines: 7, Dropped: 2}
+	tests := []struct {
+		size     int64
+		segments []string
+		want     spoolState
+	}{
+		{100, []string{"a.spool.1.gz"}, spoolState{Offset: 40, Dropped: 2, SegmentLines: 7}},
+		{100, []string{"a.spool.2.gz"}, spoolState{Offset: 40, Dropped: 2}},
+		{100, nil, spoolState{Offset: 40, Dropped: 2}},
+		{120, nil, spoolState{}},
+		{0, nil, spoolState{}},
+	}
+	for _, tt := range tests {
+		savedSpools = map[string]spoolState{"a.spool": saved}
+		s := &spool{size: tt.size, segments: tt.segments}
+		restoreSpool("a.spool", s)
+		if s.offset != tt.want.Offset || s.dropped != tt.want.Dropped || s.segmentLines != tt.want.SegmentLines {
+			t.Errorf("Error on %v %v, got offset=%d dropped=%d segment_lines=%d",
+				tt.size, tt.segments, s.offset, s.dropped, s.segmentLines)
+		}
+		if len(savedSpools) != 0 {
+			t.Errorf("Error on %v %v, saved position kept", tt.size, tt.segments)
+		}
+	}
+	savedSpools = nil
+}
+
+func TestLoadSavedSpools(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"web.state": `{"spools": {"web.stdout.spool": {"size": 10, "offset": 4}}}`,
+		"db.state":  `{"spools": {"db.stderr.spool": {"size": 20, "offset": 8}}}`,
+		"other":     `{"spools": {"other.stdout.spool": {"size": 30, "offset": 9}}}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(d, f string) { spoolDir, stateFile = d, f }(spoolDir, stateFile)
+	tests := []struct {
+		stateFile string
+		want      map[string]int64
+	}{
+		{"", map[string]int64{"web.stdout.spool": 4, "db.stderr.spool": 8}},
+		{filepath.Join(dir, "other"), map[string]int64{"other.stdout.spool": 9}},
+		{filepath.Join(dir, "missing"), map[string]int64{}},
+	}
+	for _, tt := range tests {
+		spoolDir, stateFile = dir, tt.stateFile
+		err := loadSavedSpools()
+		got := map[string]int64{}
+		for p, ss := range savedSpools {
+			got[p] = ss.Offset
+		}
+		if err != nil || len(got) != len(tt.want) {
+			t.Errorf("Error on %q, got %v, %v", tt.stateFile, got, err)
+			continue
+		}
+		for p, off := range tt.want {
+			if got[p] != off {
+				t.Errorf("Error on %q, got %v", tt.stateFile, got)
+			}
+		}
+	}
+	savedSpools = nil
+}
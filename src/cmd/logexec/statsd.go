@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const statsdInterval = 10 * time.Second
+
+var (
+	statsdAddr   string
+	statsdPrefix string
+	statsdTags   string
+
+	statsdMu   sync.Mutex
+	statsdConn net.Conn
+
+	// statsdSent holds the counter values last sent, as statsd counters are
+	// sent as increments.
+	statsdSent = map[string]int64{}
+	statsdSums = map[string]float64{}
+)
+
+func init() {
+	flag.StringVar(&statsdAddr, "statsd-addr", "",
+		"statsd address to send metrics to over UDP every 10s, e.g. localhost:8125")
+	flag.StringVar(&statsdPrefix, "statsd-prefix", "logexec.",
+		"prefix for the statsd metric names")
+	flag.StringVar(&statsdTags, "statsd-tags", "",
+		"DogStatsD tags to add to the statsd metrics, e.g. env:prod,team:web")
+}
+
+// startStatsd starts sending metrics to statsd if -statsd-addr is set.
+func startStatsd() {
+	if statsdAddr == "" {
+		return
+	}
+	conn, err := net.Dial("udp", statsdAddr)
+	if err != nil {
+		fatalf("Error connecting to statsd: %v", err)
+	}
+	statsdConn = conn
+	go func() {
+		for range time.NewTicker(statsdInterval).C {
+			flushStatsd()
+		}
+	}()
+}
+
+// flushStatsd sends the increase of each counter since the last flush and
+// the current value of each gauge.
+func flushStatsd() {
+	if statsdConn == nil {
+		return
+	}
+	statsdMu.Lock()
+	defer statsdMu.Unlock()
+
+	var b bytes.Buffer
+	suffix := ""
+	if statsdTags != "" {
+		suffix = "|#" + statsdTags
+	}
+	counter := func(name string, total int64) {
+		if n := total - statsdSent[name]; n > 0 {
+			fmt.Fprintf(&b, "%s%s:%d|c%s\n", statsdPrefix, name, n, suffix)
+		}
+		statsdSent[name] = total
+	}
+	gauge := func(name string, value int64) {
+		fmt.Fprintf(&b, "%s%s:%d|g%s\n", statsdPrefix, name, value, suffix)
+	}
+
+	totals := streamTotals()
+	for i, s := range outputStreams() {
+		counter(s.name+".lines", totals[i].Lines)
+		counter(s.name+".bytes", totals[i].Bytes)
+		counter(s.name+".dropped", totals[i].dropped)
+		counter(s.name+".truncated", totals[i].Truncated)
+	}
+	counter("sink_errors", atomic.LoadInt64(&sinkErrors))
+	counter("runs", atomic.LoadInt64(&runs))
+	gauge("children_running", atomic.LoadInt64(&childrenRunning))
+	gauge("queue_depth", int64(queueDepth()))
+	for _, m := range lineMetrics {
+		count, sum := m.values()
+		name := "metric." + m.name
+		if !m.extracts() {
+			counter(name, count)
+			continue
+		}
+		counter(name+".count", count)
+		if v := sum - statsdSums[name]; v != 0 {
+			fmt.Fprintf(&b, "%s%s.sum:%v|c%s\n", statsdPrefix, name, v, suffix)
+		}
+		statsdSums[name] = sum
+	}
+
+	if _, err := statsdConn.Write(b.Bytes()); err != nil {
+		debugf("Sending metrics to statsd: %v", err)
+	}
+}
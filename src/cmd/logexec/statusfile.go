@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+var statusFile string
+
+func init() {
+	flag.StringVar(&statusFile, "statusfile", "",
+		"file to write the exit status and statistics to when the command exits")
+}
+
+// writeStatusFile atomically replaces statusFile with a key=value summary of
+// the run, so monitoring scripts can check outcomes without reading syslog.
+func (run *runState) writeStatusFile(status int, children []*child) {
+	if statusFile == "" {
+		return
+	}
+
+	signal := ""
+	for _, c := range children {
+		if c.signal != 0 && c.status == status {
+			signal = signalName(c.signal)
+			break
+		}
+	}
+
+	end := time.Now()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "exit_status=%d\n", status)
+	fmt.Fprintf(&b, "signal=%s\n", signal)
+	fmt.Fprintf(&b, "start_time=%s\n", run.start.Format(time.RFC3339))
+	fmt.Fprintf(&b, "end_time=%s\n", end.Format(time.RFC3339))
+	fmt.Fprintf(&b, "duration=%.3f\n", end.Sub(run.start).Seconds())
+	for _, s := range run.streams {
+		fmt.Fprintf(&b, "%s_lines=%d\n", s.name, atomic.LoadInt64(&s.stats.Lines))
+		fmt.Fprintf(&b, "%s_bytes=%d\n", s.name, atomic.LoadInt64(&s.stats.Bytes))
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(statusFile), ".logexec-status")
+	if err == nil {
+		_, err = tmp.Write(b.Bytes())
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), 0644)
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), statusFile)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		warnf("Error writing status file: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+)
+
+var errInvalidStdin = errors.New("invalid stdin policy, want inherit, null or closed")
+
+// stdinPolicy controls what the child gets as its stdin.
+type stdinPolicy string
+
+func (p stdinPolicy) String() string {
+	return string(p)
+}
+
+func (p *stdinPolicy) Set(to string) error {
+	switch to {
+	case "inherit", "null", "closed":
+		*p = stdinPolicy(to)
+		return nil
+	}
+	return errInvalidStdin
+}
+
+var stdinMode = stdinPolicy("inherit")
+
+func init() {
+	flag.Var(&stdinMode, "stdin",
+		"stdin for the child: inherit, null (/dev/null) or closed")
+}
+
+// setupStdin connects the child's stdin according to stdinMode. A closed
+// stdin is closed by the trampoline t of the child right before the command
+// is exec'd, so that the command starts without a descriptor 0.
+func setupStdin(cmd *exec.Cmd, t *trampoline) {
+	switch stdinMode {
+	case "null", "closed":
+		cmd.Stdin = nil
+		t.CloseStdin = stdinMode == "closed"
+	default:
+		cmd.Stdin = os.Stdin
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSetupStdin(t *testing.T) {
+	self, err := os.Readlink("/proc/self/fd/0")
+	if err != nil {
+		t.Skipf("Error reading own stdin: %v", err)
+	}
+	defer func(mode stdinPolicy) { stdinMode = mode }(stdinMode)
+	tests := []struct {
+		mode   stdinPolicy
+		want   string
+		closed bool
+	}{
+		{"inherit", self, false},
+		{"null", os.DevNull, false},
+		{"closed", "", true},
+	}
+	for _, tt := range tests {
+		stdinMode = tt.mode
+		var out bytes.Buffer
+		cmd := exec.Command("readlink", "/proc/self/fd/0")
+		cmd.Stdout = &out
+		var tr trampoline
+		setupStdin(cmd, &tr)
+		err := startTrampoline(cmd, tr)
+		if err == nil {
+			err = cmd.Wait()
+		}
+		got := strings.TrimSpace(out.String())
+		if (err != nil) != tt.closed || got != tt.want {
+			t.Errorf("Error on %v, got %q, %v", tt.mode, got, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"log/syslog"
+	"strings"
 )
 
 var errInvalidLevel = errors.New("invalid log level")
@@ -90,3 +91,48 @@ func (l *logLevel) Set(to string) error {
 	*l = logLevel(v)
 	return nil
 }
+
+// streamFacility is the facility for one stream, which is -facility unless
+// it has been set.
+type streamFacility struct {
+	facility logFacility
+	set      bool
+}
+
+func (s streamFacility) String() string {
+	if !s.set {
+		return ""
+	}
+	return s.facility.String()
+}
+
+func (s *streamFacility) Set(to string) error {
+	if err := s.facility.Set(to); err != nil {
+		return err
+	}
+	s.set = true
+	return nil
+}
+
+func (s streamFacility) priority() syslog.Priority {
+	if s.set {
+		return syslog.Priority(s.facility)
+	}
+	return syslog.Priority(facility)
+}
+
+// parsePriority parses [facility.]level into level and facility, leaving
+// facility unset if it is not given. The names may be in upper case and
+// have the LOG_ prefix of syslog(3).
+func parsePriority(to string, level *logLevel, facility *streamFacility) error {
+	name := func(n string) string {
+		return strings.TrimPrefix(strings.ToLower(n), "log_")
+	}
+	if i := strings.LastIndexByte(to, '.'); i >= 0 {
+		if err := facility.Set(name(to[:i])); err != nil {
+			return err
+		}
+		to = to[i+1:]
+	}
+	return level.Set(name(to))
+}
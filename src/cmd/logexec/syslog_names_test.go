@@ -1,6 +1,7 @@
 package main
 
 import (
+	"log/syslog"
 	"testing"
 )
 
@@ -23,3 +24,17 @@ func TestLevelNames(t *testing.T) {
 		}
 	}
 }
+
+func TestStreamFacility(t *testing.T) {
+	var sf streamFacility
+	if sf.priority() != syslog.Priority(facility) {
+		t.Errorf("Error on unset, got %v", sf.priority())
+	}
+	sf.Set("local1")
+	if sf.priority() != syslog.LOG_LOCAL1 {
+		t.Errorf("Error on local1, got %v", sf.priority())
+	}
+	if err := sf.Set("nope"); err != errInvalidFacility {
+		t.Errorf("Error on nope, got %v", err)
+	}
+}
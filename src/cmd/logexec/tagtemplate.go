@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// expandTag replaces the variables in a tag: {hostname}, {cmd} for the base
+// name of the command, {pid} for the pid of logexec and {env:NAME} for the
+// value of an environment variable.
+func expandTag(tag, cmd string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(tag, '{')
+		if i < 0 {
+			b.WriteString(tag)
+			return b.String(), nil
+		}
+		j := strings.IndexByte(tag[i:], '}')
+		if j < 0 {
+			return "", fmt.Errorf("unterminated variable in tag %q", tag)
+		}
+		b.WriteString(tag[:i])
+		name := tag[i+1 : i+j]
+		switch {
+		case name == "hostname":
+			host, err := os.Hostname()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(host)
+		case name == "cmd":
+			b.WriteString(filepath.Base(cmd))
+		case name == "pid":
+			b.WriteString(strconv.Itoa(os.Getpid()))
+		case strings.HasPrefix(name, "env:"):
+			b.WriteString(os.Getenv(name[len("env:"):]))
+		default:
+			return "", fmt.Errorf("unknown variable {%s} in tag", name)
+		}
+		tag = tag[i+j+1:]
+	}
+}
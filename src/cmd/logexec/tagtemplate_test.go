@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestExpandTag(t *testing.T) {
+	host, _ := os.Hostname()
+	os.Setenv("LOGEXEC_TEST_SERVICE", "billing")
+	tests := []struct {
+		tag, want string
+		ok        bool
+	}{
+		{"plain", "plain", true},
+		{"{cmd}", "myjob", true},
+		{"{hostname}-{cmd}", host + "-myjob", true},
+		{"{env:LOGEXEC_TEST_SERVICE}/{pid}", "billing/" + strconv.Itoa(os.Getpid()), true},
+		{"{env:LOGEXEC_TEST_UNSET}x", "x", true},
+		{"{nope}", "", false},
+		{"{cmd", "", false},
+	}
+	for _, tt := range tests {
+		got, err := expandTag(tt.tag, "/usr/bin/myjob")
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("Error on %v, got %v, %v", tt.tag, got, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"logexec"
+)
+
+const tailPollInterval = 250 * time.Millisecond
+
+// tailList collects repeated -tailfile paths.
+type tailList []string
+
+func (l tailList) String() string {
+	return strings.Join(l, ", ")
+}
+
+func (l *tailList) Set(to string) error {
+	*l = append(*l, to)
+	return nil
+}
+
+var tailPaths tailList
+
+func init() {
+	flag.Var(&tailPaths, "tailfile",
+		"file written by the command to follow and log like its stdout, across rotation; may be repeated")
+}
+
+// tailer follows a file by name as tail -F does, logging the lines written
+// to it. When the file is replaced or truncated the new contents are logged
+// from the start.
+type tailer struct {
+	path string
+	f    *os.File
+	buf  []byte
+
+	sw *spoolWriter
+	q  *lineQueue
+	lw *logexec.LineWriter
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startTails starts following the -tailfile files. Lines already in them
+// are skipped. If it fails, those already followed are stopped.
+func startTails() ([]*tailer, error) {
+	var tails []*tailer
+	for _, path := range tailPaths {
+		log, err := UnixSyslog(stdoutPriority(), tag)
+		if err != nil {
+			stopTails(tails)
+			return nil, fmt.Errorf("initializing tailfile syslog: %v", err)
+		}
+		sw, err := newSpoolWriter(log, tag, "tail")
+		if err != nil {
+			log.Close()
+			stopTails(tails)
+			return nil, err
+		}
+		t := &tailer{
+			path: path,
+			buf:  make([]byte, 32*1024),
+			sw:   sw,
+			stop: make(chan struct{}),
+			done: make(chan struct{}),
+		}
+		t.q = newLineQueue(newRecordWriter(t.sw, tag, "tail", stdoutPriority()), &streamStats{})
+		t.lw = logexec.NewLineWriter(t.q, logexec.LineOptions{MaxLine: *maxLogLine})
+		if t.open() {
+			t.f.Seek(0, io.SeekEnd)
+		}
+		go t.run()
+		tails = append(tails, t)
+	}
+	return tails, nil
+}
+
+// stopTails logs what was written to the files up to now and stops
+// following them.
+func stopTails(tails []*tailer) {
+	for _, t := range tails {
+		close(t.stop)
+		<-t.done
+		t.lw.Flush()
+		t.q.Close()
+		t.sw.Close()
+		if t.f != nil {
+			t.f.Close()
+		}
+	}
+}
+
+func (t *tailer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	for {
+		t.poll()
+		select {
+		case <-t.stop:
+			t.poll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// open opens the file if it exists, returning whether it does.
+func (t *tailer) open() bool {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return false
+	}
+	t.f = f
+	return true
+}
+
+// poll logs what has been written to the file since the last poll, and
+// moves on to a new file at the path once the old one has been read to the
+// end.
+func (t *tailer) poll() {
+	if t.f == nil {
+		if !t.open() {
+			return
+		}
+		debugf("Following %s", t.path)
+	}
+	t.read()
+	cur, err := t.f.Stat()
+	if err != nil {
+		return
+	}
+	fi, err := os.Stat(t.path)
+	if err != nil || !os.SameFile(cur, fi) {
+		debugf("%s was rotated", t.path)
+		t.read()
+		t.lw.Flush()
+		t.f.Close()
+		t.f = nil
+		if err == nil {
+			t.poll()
+		}
+		return
+	}
+	if off, _ := t.f.Seek(0, io.SeekCurrent); fi.Size() < off {
+		debugf("%s was truncated", t.path)
+		t.f.Seek(0, io.SeekStart)
+		t.read()
+	}
+}
+
+// read logs the file up to its end. A partial last line is kept until the
+// rest of it is written.
+func (t *tailer) read() {
+	r := throttled(t.f)
+	for {
+		n, err := r.Read(t.buf)
+		if n > 0 {
+			t.lw.Write(t.buf[:n])
+		}
+		if err != nil {
+			if err != io.EOF {
+				warnf("Error reading %s: %v", t.path, err)
+			}
+			return
+		}
+	}
+}
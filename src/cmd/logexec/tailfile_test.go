@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"logexec"
+)
+
+func TestTailerPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var out bytes.Buffer
+	tl := &tailer{
+		path: path,
+		buf:  make([]byte, 8),
+		lw:   logexec.NewLineWriter(&lineBuffer{&out}, logexec.LineOptions{MaxLine: 100}),
+	}
+	appendFile := func(s string) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	steps := []struct {
+		do   func()
+		want string
+	}{
+		{func() {}, ""},
+		{func() { appendFile("first\nsec") }, "first\n"},
+		{func() { appendFile("ond\n") }, "second\n"},
+		{func() {
+			os.Rename(path, path+".1")
+			appendFile("new file\n")
+		}, "new file\n"},
+		{func() { ioutil.WriteFile(path, []byte("x\n"), 0600) }, "x\n"},
+		{func() { os.Remove(path) }, ""},
+		{func() { appendFile("back\n") }, "back\n"},
+	}
+	for i, s := range steps {
+		s.do()
+		out.Reset()
+		tl.poll()
+		if got := out.String(); got != s.want {
+			t.Errorf("Error on step %v, got %q", i, got)
+		}
+	}
+}
+
+// lineBuffer writes each line to a buffer followed by a newline.
+type lineBuffer struct {
+	b *bytes.Buffer
+}
+
+func (l *lineBuffer) Write(p []byte) (int, error) {
+	l.b.Write(p)
+	l.b.WriteByte('\n')
+	return len(p), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var throttleRate int64
+
+func init() {
+	flag.Int64Var(&throttleRate, "throttle", 0,
+		"most bytes of output per second to read from all streams together, delaying reads beyond it (0 for no limit)")
+}
+
+// outputThrottle is a token bucket shared by every stream, holding up to
+// a second's worth of bytes.
+var outputThrottle throttle
+
+type throttle struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// take takes n bytes from the bucket, sleeping until the bucket is no
+// longer in debt.
+func (t *throttle) take(n int, rate int64) {
+	t.mu.Lock()
+	now := time.Now()
+	if t.last.IsZero() {
+		t.tokens = float64(rate)
+	} else {
+		t.tokens += now.Sub(t.last).Seconds() * float64(rate)
+		if t.tokens > float64(rate) {
+			t.tokens = float64(rate)
+		}
+	}
+	t.last = now
+	t.tokens -= float64(n)
+	var wait time.Duration
+	if t.tokens < 0 {
+		wait = time.Duration(-t.tokens / float64(rate) * float64(time.Second))
+	}
+	t.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// throttled returns r, limited to -throttle bytes per second together with
+// the other throttled readers. Reads are delayed rather than dropped, so a
+// command that writes faster is held up by the pipe filling.
+func throttled(r io.Reader) io.Reader {
+	if throttleRate <= 0 && configFile == "" {
+		return r
+	}
+	return &throttledReader{r: r}
+}
+
+type throttledReader struct {
+	r io.Reader
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	// The rate is loaded for each read, as a reload of -config may change
+	// it. Reading at most a quarter of a second's worth at a time keeps
+	// the lines flowing evenly rather than in bursts.
+	rate := atomic.LoadInt64(&throttleRate)
+	if max := int(rate / 4); max > 0 && len(p) > max {
+		p = p[:max]
+	}
+	n, err := tr.r.Read(p)
+	if n > 0 && rate > 0 {
+		outputThrottle.take(n, rate)
+	}
+	return n, err
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleTake(t *testing.T) {
+	var th throttle
+	start := time.Now()
+	th.take(1000, 10000)
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("Error on a full bucket, got a wait of %v", d)
+	}
+	th.take(10000, 10000)
+	th.take(1000, 10000)
+	if d := time.Since(start); d < 100*time.Millisecond || d > time.Second {
+		t.Errorf("Error on an empty bucket, got a wait of %v", d)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	"logexec"
+)
+
+// trampolineEnv passes the settings of a trampoline to logexec started in
+// its place.
+const trampolineEnv = "LOGEXEC_TRAMPOLINE"
+
+// trampoline holds what has to be set up in the child itself before the
+// command is exec'd, but cannot be set from the thread that forks it: the
+// resource limits and the umask, which are shared by the whole of logexec
+// and would otherwise apply to the files it creates, the niceness on
+// systems where it is shared too, and LISTEN_PID, which is only known once
+// the child has been forked. The OOM score is set there first, so that the
+// command never runs with that of logexec. Capabilities are dropped once
+// the chroot and the resource limits, which may need them, are set, and a
+// closed stdin is closed last, as the child always gets a descriptor 0.
+type trampoline struct {
+	Path        string            `json:"path"`
+	Chroot      string            `json:"chroot,omitempty"`
+	Limits      []trampolineLimit `json:"limits,omitempty"`
+	Nice        *int              `json:"nice,omitempty"`
+	Umask       *int              `json:"umask,omitempty"`
+	DropCaps    []string          `json:"drop_caps,omitempty"`
+	NoNewPrivs  bool              `json:"no_new_privs,omitempty"`
+	ListenPID   bool              `json:"listen_pid,omitempty"`
+	CloseStdin  bool              `json:"close_stdin,omitempty"`
+	OOMScoreAdj *int              `json:"oom_score_adj,omitempty"`
+	// ErrFd is the descriptor the trampoline reports a failure on, which
+	// is closed when the command is exec'd.
+	ErrFd int `json:"err_fd"`
+}
+
+type trampolineLimit struct {
+	Resource int    `json:"resource"`
+	Cur      uint64 `json:"cur"`
+	Max      uint64 `json:"max"`
+}
+
+// trampolineError is a failure of the trampoline to run the command, as it
+// reports it to logexec. Errno is that of the failure, if any, so that the
+// exit status is that of a command that could not be started directly.
+type trampolineError struct {
+	Msg   string `json:"msg"`
+	Errno int    `json:"errno,omitempty"`
+}
+
+func (e *trampolineError) Error() string {
+	return e.Msg
+}
+
+func (e *trampolineError) Unwrap() error {
+	if e.Errno == 0 {
+		return nil
+	}
+	return syscall.Errno(e.Errno)
+}
+
+// setupTrampoline runs cmd through logexec itself when it needs any of the
+// settings of t, which then execs the command with its arguments unchanged.
+// A chroot is entered by the trampoline, as logexec cannot be exec'd from
+// inside it.
+//
+// The returned function is to be called once cmd has been started, or has
+// failed to start. It returns the error that kept the trampoline from
+// executing the command, after which cmd has exited.
+func setupTrampoline(cmd *exec.Cmd, t trampoline) (func() error, error) {
+	none := func() error { return nil }
+	if len(t.Limits) == 0 && t.Nice == nil && t.Umask == nil &&
+		len(t.DropCaps) == 0 && !t.NoNewPrivs && !t.ListenPID && !t.CloseStdin &&
+		t.OOMScoreAdj == nil {
+		return none, nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return none, fmt.Errorf("finding the logexec executable: %v", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return none, fmt.Errorf("initializing trampoline pipe: %v", err)
+	}
+	// The descriptor follows those passed on to the command.
+	t.ErrFd = 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	t.Path, t.Chroot = cmd.Path, cmd.SysProcAttr.Chroot
+	b, err := json.Marshal(t)
+	if err != nil {
+		r.Close()
+		w.Close()
+		return none, err
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, trampolineEnv+"="+string(b))
+	cmd.Path = self
+	cmd.SysProcAttr.Chroot = ""
+	return func() error {
+		w.Close()
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil || len(b) == 0 {
+			return err
+		}
+		var terr trampolineError
+		if err := json.Unmarshal(b, &terr); err != nil {
+			return fmt.Errorf("reading trampoline error: %v", err)
+		}
+		waitReaped(cmd)
+		return &terr
+	}, nil
+}
+
+// runTrampoline applies the settings passed by setupTrampoline and execs
+// the command in place of logexec. If it cannot, it reports why to logexec
+// and exits with the status a shell would, 127 if the command was not found
+// and 126 if it could not be executed.
+//
+// The settings are applied from a single thread, which execs the command,
+// since capabilities are tracked per thread.
+func runTrampoline(settings string) {
+	runtime.LockOSThread()
+	os.Unsetenv(trampolineEnv)
+	var t trampoline
+	err := json.Unmarshal([]byte(settings), &t)
+	if err == nil {
+		syscall.CloseOnExec(t.ErrFd)
+		err = t.apply()
+	}
+	if err == nil {
+		if t.CloseStdin {
+			syscall.Close(0)
+		}
+		err = syscall.Exec(t.Path, os.Args, os.Environ())
+		err = &os.PathError{Op: "fork/exec", Path: t.Path, Err: err}
+	}
+
+	terr := trampolineError{Msg: err.Error()}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		terr.Errno = int(errno)
+	}
+	b, _ := json.Marshal(terr)
+	if t.ErrFd == 0 || writeAll(t.ErrFd, b) != nil {
+		fmt.Fprintf(os.Stderr, "logexec: starting %s: %v\n", t.Path, err)
+	}
+	os.Exit(logexec.ExitStatus(&terr))
+}
+
+func writeAll(fd int, b []byte) error {
+	for len(b) > 0 {
+		n, err := syscall.Write(fd, b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func (t trampoline) apply() error {
+	if t.OOMScoreAdj != nil {
+		if err := writeOOMScoreAdj(*t.OOMScoreAdj); err != nil {
+			return fmt.Errorf("setting OOM score: %v", err)
+		}
+	}
+	if t.Chroot != "" {
+		if err := syscall.Chroot(t.Chroot); err != nil {
+			return err
+		}
+		if err := syscall.Chdir("/"); err != nil {
+			return err
+		}
+	}
+	if t.Nice != nil {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, *t.Nice); err != nil {
+			return fmt.Errorf("setting niceness: %v", err)
+		}
+	}
+	if t.Umask != nil {
+		syscall.Umask(*t.Umask)
+	}
+	if t.ListenPID {
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	}
+	if err := setLimits(t.Limits); err != nil {
+		return err
+	}
+	// Dropping capabilities comes last, as the steps above may need them.
+	return applyCapabilities(t.DropCaps, t.NoNewPrivs)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"logexec"
+)
+
+// TestMain runs the test binary as the trampoline of a command when asked
+// to, as logexec does, or as a logexec taking a lock file or activated
+// sockets.
+func TestMain(m *testing.M) {
+	if t, ok := os.LookupEnv(trampolineEnv); ok {
+		runTrampoline(t)
+	}
+	if path, ok := os.LookupEnv("LOGEXEC_TEST_LOCK"); ok {
+		os.Exit(runTestLock(path))
+	}
+	if _, ok := os.LookupEnv("LOGEXEC_TEST_ACTIVATION"); ok {
+		os.Exit(runTestActivation())
+	}
+	os.Exit(m.Run())
+}
+
+// startTrampoline starts cmd through a trampoline with t, and returns the
+// error reported by the trampoline or else the command's own.
+func startTrampoline(cmd *exec.Cmd, t trampoline) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	started, err := setupTrampoline(cmd, t)
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if terr := started(); err == nil {
+		err = terr
+	}
+	return err
+}
+
+func TestTrampolineStatus(t *testing.T) {
+	umask := 022
+	tests := []struct {
+		args   []string
+		start  bool
+		status int
+	}{
+		{[]string{"/bin/sh", "-c", "exit 3"}, true, 3},
+		{[]string{"/nonexistent/command"}, false, 127},
+		{[]string{"/etc/passwd"}, false, 126},
+		{[]string{"/"}, false, 126},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command(tt.args[0], tt.args[1:]...)
+		err := startTrampoline(cmd, trampoline{Umask: &umask})
+		if tt.start && err == nil {
+			err = cmd.Wait()
+		}
+		if _, isExit := err.(*exec.ExitError); isExit != tt.start || logexec.ExitStatus(err) != tt.status {
+			t.Errorf("Error on %v, got %v", tt.args, err)
+		}
+	}
+}
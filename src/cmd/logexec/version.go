@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+)
+
+// ServerVersion and ServerCommit describe the build of logexec, and are
+// set at build time with -ldflags "-X main.ServerVersion=...".
+var (
+	ServerVersion = "unknown"
+	ServerCommit  = "unknown"
+)
+
+var showVersion bool
+
+func init() {
+	flag.BoolVar(&showVersion, "version", false,
+		"Print the version, git commit and Go version of logexec, then exit")
+}
+
+func versionString() string {
+	return fmt.Sprintf("logexec %s (commit %s, %s)", ServerVersion, ServerCommit, runtime.Version())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	watchdog time.Duration
+
+	// lastOutput is the time in Unix nanoseconds that a line was last logged.
+	lastOutput int64
+)
+
+func init() {
+	flag.DurationVar(&watchdog, "watchdog", 0,
+		"kill the command if it produces no output for this long (e.g. 5m)")
+}
+
+func touchOutput() {
+	atomic.StoreInt64(&lastOutput, time.Now().UnixNano())
+}
+
+// startWatchdog returns a channel that ticks while the watchdog is enabled,
+// or nil if it is not.
+func startWatchdog() <-chan time.Time {
+	if watchdog <= 0 {
+		return nil
+	}
+	touchOutput()
+	interval := watchdog / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return time.NewTicker(interval).C
+}
+
+// silentFor reports how long it has been since any output was logged, and
+// whether that exceeds the watchdog timeout.
+func silentFor() (time.Duration, bool) {
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&lastOutput)))
+	return idle, idle >= watchdog
+}
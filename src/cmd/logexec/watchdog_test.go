@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSilentFor(t *testing.T) {
+	defer func(d time.Duration, last int64) { watchdog, lastOutput = d, last }(watchdog, lastOutput)
+
+	tests := []struct {
+		watchdog time.Duration
+		silent   time.Duration
+		want     bool
+	}{
+		{time.Minute, 0, false},
+		{time.Minute, 30 * time.Second, false},
+		{time.Minute, time.Minute, true},
+		{time.Minute, time.Hour, true},
+		{5 * time.Second, 10 * time.Second, true},
+	}
+	for _, tt := range tests {
+		watchdog = tt.watchdog
+		atomic.StoreInt64(&lastOutput, time.Now().Add(-tt.silent).UnixNano())
+		idle, expired := silentFor()
+		if expired != tt.want || idle < tt.silent || idle > tt.silent+time.Second {
+			t.Errorf("Error on %v after %v, got %v, %v", tt.watchdog, tt.silent, idle, expired)
+		}
+	}
+
+	watchdog = 0
+	if c := startWatchdog(); c != nil {
+		t.Errorf("Error on disabled watchdog, got a ticker")
+	}
+	watchdog = time.Minute
+	atomic.StoreInt64(&lastOutput, 0)
+	if c := startWatchdog(); c == nil {
+		t.Errorf("Error on watchdog, got no ticker")
+	}
+	// Starting the watchdog counts as output, so that a run is not killed
+	// for the silence before it.
+	if idle, expired := silentFor(); expired || idle > time.Second {
+		t.Errorf("Error on started watchdog, got %v, %v", idle, expired)
+	}
+
+	// Every line of output queued to be logged resets the watchdog.
+	atomic.StoreInt64(&lastOutput, 0)
+	q := newLineQueue(ioutil.Discard, &streamStats{})
+	q.Write([]byte("line"))
+	q.Close()
+	if idle, expired := silentFor(); expired || idle > time.Second {
+		t.Errorf("Error on output, got %v, %v", idle, expired)
+	}
+}
@@ -0,0 +1,78 @@
+package logexec
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+)
+
+// ChunkWriter writes what is read from a reader to a sink in chunks of up
+// to MaxLine bytes, each in one Write call, without splitting it into
+// lines. A chunk ends at the last newline read so far, so that lines are
+// only split when one does not fit in a chunk, and that newline is
+// removed. It is for output that is already well formed, and sinks that
+// take messages of several lines.
+//
+// Only MaxLine and Stats of the LineOptions are used. Stats counts chunks
+// as lines.
+type ChunkWriter struct {
+	sink     io.Writer
+	maxChunk int
+	stats    *StreamStats
+}
+
+// NewChunkWriter returns a ChunkWriter that writes chunks to sink.
+func NewChunkWriter(sink io.Writer, opts LineOptions) *ChunkWriter {
+	maxChunk := opts.MaxLine
+	if maxChunk <= 0 {
+		maxChunk = DefaultMaxLine
+	}
+	return &ChunkWriter{sink: sink, maxChunk: maxChunk, stats: opts.Stats}
+}
+
+// ReadFrom writes the chunks read from r until EOF, including a final
+// chunk without a newline.
+func (w *ChunkWriter) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, w.maxChunk+1)
+	var n int64
+	used := 0
+	for {
+		m, err := r.Read(buf[used:])
+		n += int64(m)
+		used += m
+		if err == io.EOF {
+			if used == 0 {
+				return n, nil
+			}
+			return n, w.writeChunk(buf[:used])
+		}
+		if err != nil {
+			return n, err
+		}
+		// The buffer has room for a newline after a full chunk, so that
+		// a chunk that just fits is not split from its newline.
+		end := bytes.LastIndexByte(buf[:used], '\n') + 1
+		if end == 0 && used == len(buf) {
+			end = w.maxChunk
+		}
+		if end > 0 {
+			if err := w.writeChunk(buf[:end]); err != nil {
+				return n, err
+			}
+			used = copy(buf, buf[end:used])
+		}
+	}
+}
+
+func (w *ChunkWriter) writeChunk(c []byte) error {
+	c = bytes.TrimSuffix(c, []byte("\n"))
+	if _, err := w.sink.Write(c); err != nil {
+		return err
+	}
+	if w.stats != nil {
+		atomic.AddInt64(&w.stats.Lines, 1)
+		atomic.AddInt64(&w.stats.Bytes, int64(len(c)))
+		storeMax(&w.stats.Longest, int64(len(c)))
+	}
+	return nil
+}
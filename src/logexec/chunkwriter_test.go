@@ -0,0 +1,45 @@
+package logexec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkWriter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"a\nbb\n" + strings.Repeat("c", 20) + "\nend", []string{"a\nbb", "cccccccc", "cccccccc", "cccc", "end"}},
+		{"12345678\n", []string{"12345678"}},
+		{"one\ntwo\n", []string{"one\ntwo"}},
+		{"\n", []string{""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var rec lineRecorder
+		w := NewChunkWriter(&rec, LineOptions{MaxLine: 8})
+		if n, err := w.ReadFrom(strings.NewReader(tt.in)); n != int64(len(tt.in)) || err != nil {
+			t.Errorf("Error on %q, got %v, %v", tt.in, n, err)
+		}
+		if !reflect.DeepEqual(rec.lines, tt.want) {
+			t.Errorf("Error on %q, got %q", tt.in, rec.lines)
+		}
+	}
+}
+
+func TestChunkWriterStats(t *testing.T) {
+	var rec lineRecorder
+	var stats StreamStats
+	w := NewChunkWriter(&rec, LineOptions{MaxLine: 8, Stats: &stats})
+	w.ReadFrom(strings.NewReader("a\nbb\n" + strings.Repeat("c", 20) + "\nend"))
+	if want := (StreamStats{Lines: 5, Bytes: 27, Longest: 8}); stats != want {
+		t.Errorf("Error on stats, got %+v", stats)
+	}
+
+	w = NewChunkWriter(failingWriter{errNoCommand}, LineOptions{})
+	if _, err := w.ReadFrom(strings.NewReader("a\nb\n")); err != errNoCommand {
+		t.Errorf("Error on failing sink, got %v", err)
+	}
+}
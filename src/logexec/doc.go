@@ -0,0 +1,13 @@
+// Package logexec runs commands and sends each line of their output to a
+// writer, such as a syslog.Writer. It lets Go programs embed the basic
+// exec-and-log behavior instead of running the logexec binary.
+//
+// The logexec command runs each of its commands with a Runner. What else
+// it does, such as restarting, spooling, signal mapping and resource
+// limits, is not part of the package, and is hooked in through the
+// StartCommand, WaitCommand and Copy fields of the Runner.
+//
+//	w, _ := syslog.New(syslog.LOG_INFO|syslog.LOG_LOCAL0, "myjob")
+//	r := &logexec.Runner{Args: []string{"myjob", "-v"}, Stdout: w, Stderr: w}
+//	res, err := r.Run(ctx)
+package logexec
@@ -0,0 +1,197 @@
+package logexec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// MinMaxLine is the shortest MaxLine, which leaves room for a character
+// before the "..." of a truncated line. Shorter ones are raised to it.
+const MinMaxLine = 4
+
+// LineOptions configures a LineWriter.
+type LineOptions struct {
+	// MaxLine is the length lines are truncated to, DefaultMaxLine if zero
+	// and at least MinMaxLine.
+	MaxLine int
+	// Sanitize escapes control characters other than tab as # followed by
+	// three octal digits, the way rsyslog does, and replaces invalid UTF-8
+	// with U+FFFD.
+	Sanitize bool
+	// Stats, if set, counts the lines written to the sink. It is updated
+	// atomically, so it can be read while lines are being written.
+	Stats *StreamStats
+}
+
+// LineWriter splits what is written to it into lines, and writes each line
+// to a sink in one Write call with surrounding whitespace and the newline
+// removed. Lines longer than MaxLine are truncated to end in "...". This is
+// how the logexec command logs each line of output.
+//
+// A LineWriter is not safe for concurrent use.
+type LineWriter struct {
+	sink     io.Writer
+	maxLine  int
+	sanitize bool
+	stats    *StreamStats
+
+	// line holds the start of the current line, up to twice maxLine bytes,
+	// and lineLen its full length so far.
+	line    []byte
+	lineLen int64
+	err     error
+}
+
+// NewLineWriter returns a LineWriter that writes lines to sink.
+func NewLineWriter(sink io.Writer, opts LineOptions) *LineWriter {
+	maxLine := opts.MaxLine
+	if maxLine <= 0 {
+		maxLine = DefaultMaxLine
+	} else if maxLine < MinMaxLine {
+		maxLine = MinMaxLine
+	}
+	return &LineWriter{
+		sink:     sink,
+		maxLine:  maxLine,
+		sanitize: opts.Sanitize,
+		stats:    opts.Stats,
+	}
+}
+
+// Write writes every complete line in p to the sink, keeping a final
+// partial line until the rest of it is written or Flush is called. Once
+// the sink has returned an error, Write returns that error.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		part := p
+		if i >= 0 {
+			part = p[:i]
+		}
+		w.lineLen += int64(len(part))
+		if room := 2*w.maxLine - len(w.line); room > 0 {
+			if len(part) > room {
+				part = part[:room]
+			}
+			w.line = append(w.line, part...)
+		}
+		if i < 0 {
+			break
+		}
+		p = p[i+1:]
+		if err := w.writeLine(); err != nil {
+			return n - len(p), err
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom writes the lines read from r until EOF, including a final line
+// without a newline.
+func (w *LineWriter) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var n int64
+	for {
+		m, err := r.Read(buf)
+		n += int64(m)
+		if m > 0 {
+			if _, werr := w.Write(buf[:m]); werr != nil {
+				return n, werr
+			}
+		}
+		if err == io.EOF {
+			return n, w.Flush()
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// Flush writes a partial line that has not been ended by a newline.
+func (w *LineWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.lineLen == 0 {
+		return nil
+	}
+	return w.writeLine()
+}
+
+func (w *LineWriter) writeLine() error {
+	l := bytes.TrimSpace(w.line)
+	overflow := w.lineLen > int64(len(w.line))
+	if w.stats != nil {
+		storeMax(&w.stats.Longest, w.lineLen)
+		if overflow || len(l) > w.maxLine {
+			atomic.AddInt64(&w.stats.Truncated, 1)
+		}
+	}
+	if len(l) > w.maxLine {
+		l = l[:w.maxLine-3]
+		l = append(l, "..."...)
+	}
+	if w.sanitize {
+		l = sanitize(l)
+	}
+	w.line = w.line[:0]
+	w.lineLen = 0
+
+	if _, err := w.sink.Write(l); err != nil {
+		w.err = err
+		return err
+	}
+	if w.stats != nil {
+		atomic.AddInt64(&w.stats.Lines, 1)
+		atomic.AddInt64(&w.stats.Bytes, int64(len(l)))
+	}
+	return nil
+}
+
+// sanitize escapes control characters and replaces invalid UTF-8 in l,
+// returning l itself if there is nothing to change.
+func sanitize(l []byte) []byte {
+	clean := true
+	for _, c := range l {
+		if c < ' ' && c != '\t' || c == 0x7f || c >= utf8.RuneSelf {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return l
+	}
+
+	var b bytes.Buffer
+	for len(l) > 0 {
+		r, size := utf8.DecodeRune(l)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b.WriteRune(utf8.RuneError)
+		case r < ' ' && r != '\t', r == 0x7f:
+			fmt.Fprintf(&b, "#%03o", r)
+		default:
+			b.Write(l[:size])
+		}
+		l = l[size:]
+	}
+	return b.Bytes()
+}
+
+// storeMax atomically raises *addr to v if v is larger.
+func storeMax(addr *int64, v int64) {
+	for {
+		old := atomic.LoadInt64(addr)
+		if v <= old || atomic.CompareAndSwapInt64(addr, old, v) {
+			return
+		}
+	}
+}
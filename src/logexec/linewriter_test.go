@@ -0,0 +1,63 @@
+package logexec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		writes []string
+		opts   LineOptions
+		want   []string
+	}{
+		{[]string{"one\ntwo\n"}, LineOptions{}, []string{"one", "two"}},
+		{[]string{"par", "tial\n", "  last\r\n"}, LineOptions{}, []string{"partial", "last"}},
+		{[]string{"\n"}, LineOptions{}, []string{""}},
+		{[]string{"no newline"}, LineOptions{}, []string{"no newline"}},
+		{[]string{"0123456789\n"}, LineOptions{MaxLine: 8}, []string{"01234..."}},
+		{[]string{"0123456789\n"}, LineOptions{MaxLine: 1}, []string{"0..."}},
+		{[]string{strings.Repeat("x", 20), strings.Repeat("y", 20) + "\nz\n"}, LineOptions{MaxLine: 8}, []string{"xxxxx...", "z"}},
+		{[]string{"bell\a\x1b[0m\xff é\n"}, LineOptions{Sanitize: true}, []string{"bell#007#033[0m� é"}},
+		{[]string{"bell\a\n"}, LineOptions{}, []string{"bell\a"}},
+	}
+	for _, tt := range tests {
+		var rec lineRecorder
+		w := NewLineWriter(&rec, tt.opts)
+		for _, s := range tt.writes {
+			if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+				t.Errorf("Error on %q, got %v, %v", s, n, err)
+			}
+		}
+		w.Flush()
+		if !reflect.DeepEqual(rec.lines, tt.want) {
+			t.Errorf("Error on %q, got %q", tt.writes, rec.lines)
+		}
+	}
+}
+
+func TestLineWriterReadFrom(t *testing.T) {
+	var rec lineRecorder
+	var stats StreamStats
+	w := NewLineWriter(&rec, LineOptions{MaxLine: 8, Stats: &stats})
+	n, err := w.ReadFrom(strings.NewReader("a\nbb\n" + strings.Repeat("c", 30) + "\nend"))
+	if n != 39 || err != nil {
+		t.Errorf("Error on ReadFrom, got %v, %v", n, err)
+	}
+	if want := []string{"a", "bb", "ccccc...", "end"}; !reflect.DeepEqual(rec.lines, want) {
+		t.Errorf("Error on lines, got %q", rec.lines)
+	}
+	if want := (StreamStats{Lines: 4, Bytes: 14, Truncated: 1, Longest: 30}); stats != want {
+		t.Errorf("Error on stats, got %+v", stats)
+	}
+
+	werr := failingWriter{errNoCommand}
+	w = NewLineWriter(werr, LineOptions{})
+	if _, err := w.ReadFrom(strings.NewReader("a\nb\n")); err != errNoCommand {
+		t.Errorf("Error on failing sink, got %v", err)
+	}
+	if _, err := w.Write([]byte("c\n")); err != errNoCommand {
+		t.Errorf("Error on write after failure, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package logexec
+
+import (
+	"io"
+	"log/syslog"
+	"regexp"
+)
+
+// Processor inspects or changes a record on its way to a sink. Process
+// returns false to drop the record.
+type Processor interface {
+	Process(r *Record) bool
+}
+
+// ProcessorFunc adapts a function to a Processor.
+type ProcessorFunc func(r *Record) bool
+
+func (f ProcessorFunc) Process(r *Record) bool {
+	return f(r)
+}
+
+// Chain is a Processor that applies each of its processors in order,
+// stopping at the first one that drops the record.
+type Chain []Processor
+
+func (c Chain) Process(r *Record) bool {
+	for _, p := range c {
+		if !p.Process(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Drop returns a Processor that drops the records whose line matches re.
+func Drop(re *regexp.Regexp) Processor {
+	return ProcessorFunc(func(r *Record) bool {
+		return !re.Match(r.Line)
+	})
+}
+
+// Rewrite returns a Processor that replaces the matches of re in each line
+// with repl, which may refer to submatches as in regexp.Expand.
+func Rewrite(re *regexp.Regexp, repl string) Processor {
+	return ProcessorFunc(func(r *Record) bool {
+		r.Line = re.ReplaceAll(r.Line, []byte(repl))
+		return true
+	})
+}
+
+// Relevel returns a Processor that gives the records whose line matches re
+// the syslog level of level, keeping their facility.
+func Relevel(re *regexp.Regexp, level syslog.Priority) Processor {
+	return ProcessorFunc(func(r *Record) bool {
+		if re.Match(r.Line) {
+			r.Priority = r.Priority&^7 | level&7
+		}
+		return true
+	})
+}
+
+// Enrich returns a Processor that appends key=value to each line.
+func Enrich(key, value string) Processor {
+	suffix := " " + key + "=" + value
+	return ProcessorFunc(func(r *Record) bool {
+		r.Line = append(r.Line[:len(r.Line):len(r.Line)], suffix...)
+		return true
+	})
+}
+
+// ProcessWriter returns a writer that passes each Write through p as the
+// Line of a copy of r, and writes the lines p keeps to w. The priority of
+// the record is ignored. Used as the sink of a LineWriter, it filters and
+// transforms each line of output.
+func ProcessWriter(w io.Writer, p Processor, r Record) io.Writer {
+	return &processWriter{w: w, p: p, record: r}
+}
+
+type processWriter struct {
+	w      io.Writer
+	p      Processor
+	record Record
+}
+
+func (pw *processWriter) Write(b []byte) (int, error) {
+	r := pw.record
+	r.Line = b
+	if !pw.p.Process(&r) {
+		return len(b), nil
+	}
+	if _, err := pw.w.Write(r.Line); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,55 @@
+package logexec
+
+import (
+	"context"
+	"log/syslog"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	chain := Chain{
+		Drop(regexp.MustCompile(`^debug`)),
+		Rewrite(regexp.MustCompile(`password=\S+`), "password=xxx"),
+		Relevel(regexp.MustCompile(`(?i)error`), syslog.LOG_ERR),
+		Enrich("host", "web1"),
+	}
+	tests := []struct {
+		line     string
+		keep     bool
+		want     string
+		priority syslog.Priority
+	}{
+		{"debug: noise", false, "debug: noise", syslog.LOG_LOCAL0 | syslog.LOG_INFO},
+		{"login password=hunter2 ok", true, "login password=xxx ok host=web1", syslog.LOG_LOCAL0 | syslog.LOG_INFO},
+		{"ERROR: disk full", true, "ERROR: disk full host=web1", syslog.LOG_LOCAL0 | syslog.LOG_ERR},
+	}
+	for _, tt := range tests {
+		line := []byte(tt.line)
+		r := Record{Line: line, Priority: syslog.LOG_LOCAL0 | syslog.LOG_INFO}
+		if keep := chain.Process(&r); keep != tt.keep || string(r.Line) != tt.want || r.Priority != tt.priority {
+			t.Errorf("Error on %q, got %v, %q, %v", tt.line, keep, r.Line, r.Priority)
+		}
+		if string(line) != tt.line {
+			t.Errorf("Error on %q, original changed to %q", tt.line, line)
+		}
+	}
+}
+
+func TestRunnerProcessors(t *testing.T) {
+	var stdout lineRecorder
+	r := &Runner{
+		Args:       []string{"sh", "-c", "echo keep; echo skip; echo more"},
+		Stdout:     &stdout,
+		Tag:        "job",
+		Processors: Chain{Drop(regexp.MustCompile("skip")), Enrich("tag", "job")},
+	}
+	res, err := r.Run(context.Background())
+	if want := []string{"keep tag=job", "more tag=job"}; err != nil || !reflect.DeepEqual(stdout.lines, want) {
+		t.Errorf("Error on processors, got %q, %v", stdout.lines, err)
+	}
+	if res.Stdout.Lines != 3 {
+		t.Errorf("Error on lines, got %v", res.Stdout.Lines)
+	}
+}
@@ -0,0 +1,349 @@
+package logexec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// DefaultMaxLine is the length lines are truncated to when Runner.MaxLine
+// is not set, the same as the -maxline default of the logexec command.
+const DefaultMaxLine = 8 * 1024
+
+var (
+	errNoCommand  = errors.New("logexec: no command")
+	errNotRunning = errors.New("logexec: command is not running")
+)
+
+// Runner runs a command and writes each line of its stdout and stderr to
+// Stdout and Stderr.
+type Runner struct {
+	// Args holds the command and its arguments.
+	Args []string
+	// Env is the environment of the command, that of the caller if nil.
+	Env []string
+	// Dir is the working directory of the command, that of the caller if
+	// empty.
+	Dir string
+	// Stdin is the standard input of the command, the null device if nil.
+	Stdin io.Reader
+
+	// Stdout and Stderr receive each line of the command's output in one
+	// Write call, with surrounding whitespace and the newline removed.
+	// Output is discarded if they are nil.
+	Stdout, Stderr io.Writer
+	// MaxLine is the length lines are truncated to, DefaultMaxLine if zero.
+	MaxLine int
+	// Tag is the tag of the records given to Processors.
+	Tag string
+	// Processors, if set, filter and transform each line before it is
+	// written to Stdout or Stderr. Lines they drop are still counted in the
+	// Result.
+	Processors Chain
+	// Signals are caught while the command runs and passed on to it with
+	// Signal.
+	Signals []os.Signal
+
+	// StartCommand and WaitCommand, if set, start and wait for the command
+	// in place of cmd.Start and cmd.Wait. StartCommand may set up cmd
+	// further before starting it, such as its SysProcAttr, ExtraFiles or
+	// a Stdout of its own, in which case no output is read from stdout. A
+	// program that reaps every child itself, as an init process does, uses
+	// them so that the exit of the command is handed back to WaitCommand
+	// instead of being lost to the reaper. WaitCommand is called as soon as
+	// the command has started, while its output is still being read.
+	StartCommand func(cmd *exec.Cmd) error
+	WaitCommand  func(cmd *exec.Cmd) error
+	// Copy, if set, reads stream, "stdout" or "stderr", from r and writes
+	// its lines to w in place of a LineWriter with opts, such as to read
+	// at a limited rate or write several lines at once. The lines are
+	// counted in the Result if it uses opts.Stats. Once it returns, the
+	// rest of r is discarded, and an error reading r after it has been
+	// closed on ctx being done is not reported.
+	Copy func(stream string, w io.Writer, r io.Reader, opts LineOptions) error
+
+	// The hooks below are called, when set, as the run progresses. OnLine
+	// and OnSinkError may be called from the goroutines of stdout and
+	// stderr at the same time.
+
+	// OnStart is called once the command has started, with its pid.
+	OnStart func(pid int)
+	// OnLine is called with each line that the Processors keep, before it
+	// is written.
+	OnLine func(r Record)
+	// OnSignal is called with each signal passed on to the command.
+	OnSignal func(sig os.Signal)
+	// OnSinkError is called when writing a line of stream to Stdout or
+	// Stderr fails, after which the rest of the stream is discarded.
+	OnSinkError func(stream string, err error)
+	// OnExit is called with the result once the command has exited and
+	// all of its output has been written.
+	OnExit func(res Result)
+
+	mu      sync.Mutex
+	process *os.Process
+
+	// The fields below hold the command between Start and Wait.
+	cmd         *exec.Cmd
+	start       time.Time
+	res         Result
+	copies      sync.WaitGroup
+	copyErrs    [2]error
+	copied      chan struct{}
+	waited      chan error
+	stopSignals func()
+}
+
+// StreamStats counts what was written from one of the output streams.
+type StreamStats struct {
+	Lines     int64
+	Bytes     int64
+	Truncated int64
+	Longest   int64
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	// Status is the exit status of the command, as given by ExitStatus.
+	Status int
+	// Stdout and Stderr count the output of the command.
+	Stdout, Stderr StreamStats
+	// Duration is how long the command ran.
+	Duration time.Duration
+}
+
+// Run starts the command and waits for it to exit and for all of its
+// output to be written. The command is killed if ctx is done first. A
+// Runner runs one command at a time.
+//
+// The error is that from starting the command, or the first error writing
+// its output, after which the rest of that stream is discarded. The exit
+// status of a command that exits non-zero is only reported in the Result.
+func (r *Runner) Run(ctx context.Context) (Result, error) {
+	if err := r.Start(ctx); err != nil {
+		return Result{Status: ExitStatus(err)}, err
+	}
+	return r.Wait()
+}
+
+// Start starts the command and the reading of its output, which Wait then
+// waits for. The command is killed if ctx is done before it exits, and
+// its output is no longer read once ctx is done, even if a process the
+// command left behind still holds it open.
+func (r *Runner) Start(ctx context.Context) error {
+	if len(r.Args) == 0 {
+		return errNoCommand
+	}
+	cmd := exec.CommandContext(ctx, r.Args[0], r.Args[1:]...)
+	cmd.Env = r.Env
+	cmd.Dir = r.Dir
+	cmd.Stdin = r.Stdin
+	// The pipes are created here rather than with cmd.StdoutPipe so that
+	// the command can be waited for while its output is still read.
+	var pipes [2]*os.File
+	var ends []*os.File
+	defer func() {
+		for _, f := range ends {
+			f.Close()
+		}
+	}()
+	for i := range pipes {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			for _, f := range pipes[:i] {
+				f.Close()
+			}
+			return err
+		}
+		pipes[i] = pr
+		ends = append(ends, pw)
+	}
+	cmd.Stdout, cmd.Stderr = ends[0], ends[1]
+
+	start := r.StartCommand
+	if start == nil {
+		start = (*exec.Cmd).Start
+	}
+	if err := start(cmd); err != nil {
+		pipes[0].Close()
+		pipes[1].Close()
+		return err
+	}
+	r.cmd, r.start, r.res = cmd, time.Now(), Result{}
+	r.mu.Lock()
+	r.process = cmd.Process
+	r.mu.Unlock()
+	if r.OnStart != nil {
+		r.OnStart(cmd.Process.Pid)
+	}
+	r.stopSignals = r.forwardSignals()
+
+	r.waited = make(chan error, 1)
+	go func() {
+		wait := r.WaitCommand
+		if wait == nil {
+			wait = (*exec.Cmd).Wait
+		}
+		err := wait(cmd)
+		r.mu.Lock()
+		r.process = nil
+		r.mu.Unlock()
+		r.waited <- err
+	}()
+
+	// A process the command left behind may hold its output open after it
+	// has exited or been killed, so the pipes are closed rather than read
+	// to the end once ctx is done.
+	r.copied = make(chan struct{})
+	go func(copied <-chan struct{}) {
+		select {
+		case <-ctx.Done():
+			pipes[0].Close()
+			pipes[1].Close()
+		case <-copied:
+		}
+	}(r.copied)
+
+	r.copyErrs = [2]error{}
+	r.copies.Add(2)
+	go func() {
+		defer r.copies.Done()
+		r.copyErrs[0] = r.copyStream("stdout", r.Stdout, pipes[0], &r.res.Stdout)
+		pipes[0].Close()
+	}()
+	go func() {
+		defer r.copies.Done()
+		r.copyErrs[1] = r.copyStream("stderr", r.Stderr, pipes[1], &r.res.Stderr)
+		pipes[1].Close()
+	}()
+	return nil
+}
+
+// Wait waits for the command started by Start to exit and for all of its
+// output to be written, and returns the result of the run as Run does.
+func (r *Runner) Wait() (Result, error) {
+	if r.cmd == nil {
+		return Result{Status: 1}, errNotRunning
+	}
+	err := <-r.waited
+	r.res.Duration = time.Since(r.start)
+	r.res.Status = ExitStatus(err)
+	r.copies.Wait()
+	close(r.copied)
+	r.stopSignals()
+	r.cmd = nil
+
+	res := r.res
+	if r.OnExit != nil {
+		r.OnExit(res)
+	}
+	for _, err := range r.copyErrs {
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// forwardSignals passes the Signals caught on to the command until the
+// returned function is called.
+func (r *Runner) forwardSignals() (stop func()) {
+	if len(r.Signals) == 0 {
+		return func() {}
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, r.Signals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				r.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// Signal sends sig to the running command and calls OnSignal.
+func (r *Runner) Signal(sig os.Signal) error {
+	r.mu.Lock()
+	p := r.process
+	r.mu.Unlock()
+	if p == nil {
+		return errNotRunning
+	}
+	if r.OnSignal != nil {
+		r.OnSignal(sig)
+	}
+	return p.Signal(sig)
+}
+
+// streamWriter passes each line of a stream through the Processors and
+// OnLine to w, reporting errors writing to OnSinkError.
+type streamWriter struct {
+	r      *Runner
+	w      io.Writer
+	stream string
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	rec := Record{Time: time.Now(), Tag: sw.r.Tag, Stream: sw.stream, Line: b}
+	if !sw.r.Processors.Process(&rec) {
+		return len(b), nil
+	}
+	if sw.r.OnLine != nil {
+		sw.r.OnLine(rec)
+	}
+	if sw.w == nil {
+		return len(b), nil
+	}
+	if _, err := sw.w.Write(rec.Line); err != nil {
+		if sw.r.OnSinkError != nil {
+			sw.r.OnSinkError(sw.stream, err)
+		}
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (r *Runner) maxLine() int {
+	if r.MaxLine > 0 {
+		return r.MaxLine
+	}
+	return DefaultMaxLine
+}
+
+// copyStream writes each line of stream read from rd to w, with Copy or a
+// LineWriter, and counts them in stats. After an error writing, the rest
+// of rd is read and discarded so that the command does not block. If rd is
+// closed by a cancelled run, the line read so far is written.
+func (r *Runner) copyStream(stream string, w io.Writer, rd io.Reader, stats *StreamStats) error {
+	sw := &streamWriter{r, w, stream}
+	opts := LineOptions{MaxLine: r.maxLine(), Stats: stats}
+	var err error
+	if r.Copy != nil {
+		if err = r.Copy(stream, sw, rd, opts); errors.Is(err, os.ErrClosed) {
+			err = nil
+		}
+	} else {
+		lw := NewLineWriter(sw, opts)
+		_, err = lw.ReadFrom(rd)
+		if errors.Is(err, os.ErrClosed) {
+			err = lw.Flush()
+		}
+	}
+	io.Copy(ioutil.Discard, rd)
+	return err
+}
@@ -0,0 +1,211 @@
+package logexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// lineRecorder records each line written to it.
+type lineRecorder struct {
+	lines []string
+}
+
+func (l *lineRecorder) Write(b []byte) (int, error) {
+	l.lines = append(l.lines, string(b))
+	return len(b), nil
+}
+
+func TestRunnerRun(t *testing.T) {
+	var stdout, stderr lineRecorder
+	r := &Runner{
+		Args:    []string{"sh", "-c", "echo ' one '; echo two; echo oops >&2; echo 0123456789; exit 3"},
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+		MaxLine: 8,
+	}
+	res, err := r.Run(context.Background())
+	if err != nil || res.Status != 3 {
+		t.Errorf("Error on status, got %v, %v", res.Status, err)
+	}
+	if want := []string{"one", "two", "01234..."}; !reflect.DeepEqual(stdout.lines, want) {
+		t.Errorf("Error on stdout, got %q", stdout.lines)
+	}
+	if want := []string{"oops"}; !reflect.DeepEqual(stderr.lines, want) {
+		t.Errorf("Error on stderr, got %q", stderr.lines)
+	}
+	want := StreamStats{Lines: 3, Bytes: 14, Truncated: 1, Longest: 10}
+	if res.Stdout != want {
+		t.Errorf("Error on stdout stats, got %+v", res.Stdout)
+	}
+}
+
+func TestRunnerErrors(t *testing.T) {
+	res, err := (&Runner{Args: []string{"/nonexistent/command"}}).Run(context.Background())
+	if err == nil || res.Status != 127 {
+		t.Errorf("Error on missing command, got %v, %v", res.Status, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	res, _ = (&Runner{Args: []string{"sleep", "10"}}).Run(ctx)
+	if res.Status != 137 {
+		t.Errorf("Error on cancel, got %v", res.Status)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var held lineRecorder
+	res, err = (&Runner{Args: []string{"sh", "-c", "echo started; sleep 3 & wait"}, Stdout: &held}).Run(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second || err != nil || res.Status != 137 ||
+		!reflect.DeepEqual(held.lines, []string{"started"}) {
+		t.Errorf("Error on cancel with output held open, got %v, %v, %v, %q", elapsed, res.Status, err, held.lines)
+	}
+
+	werr := errors.New("write failed")
+	r := &Runner{Args: []string{"seq", "100000"}, Stdout: failingWriter{werr}}
+	if res, err := r.Run(context.Background()); err != werr || res.Status != 0 {
+		t.Errorf("Error on write error, got %v, %v", res.Status, err)
+	}
+
+	var b bytes.Buffer
+	r = &Runner{Args: []string{"echo", strings.Repeat("x", 20)}, Stdout: &b}
+	if res, err := r.Run(context.Background()); err != nil || b.Len() != 20 || res.Stdout.Truncated != 0 {
+		t.Errorf("Error on default max line, got %v, %q", err, b.String())
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (f failingWriter) Write([]byte) (int, error) {
+	return 0, f.err
+}
+
+func TestRunnerHooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	event := func(format string, v ...interface{}) {
+		mu.Lock()
+		events = append(events, fmt.Sprintf(format, v...))
+		mu.Unlock()
+	}
+	werr := errors.New("write failed")
+	r := &Runner{
+		Args:        []string{"sh", "-c", "echo out; sleep 1; echo late >&2"},
+		Stderr:      failingWriter{werr},
+		OnLine:      func(rec Record) { event("line %s %s", rec.Stream, rec.Line) },
+		OnSinkError: func(stream string, err error) { event("sink error %s %v", stream, err) },
+		OnSignal:    func(sig os.Signal) { event("signal %v", sig) },
+		OnExit:      func(res Result) { event("exit %d", res.Status) },
+	}
+	r.OnStart = func(pid int) {
+		event("start")
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			r.Signal(syscall.SIGUSR1)
+		}()
+	}
+	res, _ := r.Run(context.Background())
+	want := []string{"start", "line stdout out", "signal user defined signal 1", "exit 138"}
+	if res.Status != 138 || !reflect.DeepEqual(events, want) {
+		t.Errorf("Error on hooks, got %v, %q", res.Status, events)
+	}
+	if err := r.Signal(syscall.SIGUSR1); err != errNotRunning {
+		t.Errorf("Error on signal after exit, got %v", err)
+	}
+
+	events = nil
+	r = &Runner{
+		Args:        []string{"sh", "-c", "echo a >&2; echo b >&2"},
+		Stderr:      failingWriter{werr},
+		OnSinkError: func(stream string, err error) { event("sink error %s %v", stream, err) },
+	}
+	if _, err := r.Run(context.Background()); err != werr || !reflect.DeepEqual(events, []string{"sink error stderr write failed"}) {
+		t.Errorf("Error on sink error, got %v, %q", err, events)
+	}
+}
+
+func TestRunnerCommandHooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	event := func(s string) {
+		mu.Lock()
+		events = append(events, s)
+		mu.Unlock()
+	}
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// The command exits while a process it left behind holds its stderr
+	// open, and its stdout goes to the file StartCommand gives it.
+	var stderr lineRecorder
+	r := &Runner{
+		Args:   []string{"sh", "-c", "echo out; echo err >&2; (sleep 0.3; echo late >&2) & exit 2"},
+		Stderr: &stderr,
+		StartCommand: func(cmd *exec.Cmd) error {
+			event("start")
+			cmd.Stdout = f
+			return cmd.Start()
+		},
+		WaitCommand: func(cmd *exec.Cmd) error {
+			err := cmd.Wait()
+			event("wait")
+			return err
+		},
+		Copy: func(stream string, w io.Writer, r io.Reader, opts LineOptions) error {
+			event("copy " + stream)
+			_, err := NewLineWriter(w, opts).ReadFrom(r)
+			return err
+		},
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Wait()
+	if err != nil || res.Status != 2 || res.Stdout.Lines != 0 || res.Stderr.Lines != 2 || res.Duration >= 300*time.Millisecond {
+		t.Errorf("Error on result, got %+v, %v", res, err)
+	}
+	if want := []string{"err", "late"}; !reflect.DeepEqual(stderr.lines, want) {
+		t.Errorf("Error on stderr, got %q", stderr.lines)
+	}
+	if b, _ := ioutil.ReadFile(f.Name()); string(b) != "out\n" {
+		t.Errorf("Error on stdout, got %q", b)
+	}
+	sort.Strings(events[1:3])
+	if want := []string{"start", "copy stderr", "copy stdout", "wait"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("Error on hooks, got %q", events)
+	}
+
+	serr := errors.New("start failed")
+	r = &Runner{Args: []string{"true"}, StartCommand: func(*exec.Cmd) error { return serr }}
+	if res, err := r.Run(context.Background()); err != serr || res.Status != 1 {
+		t.Errorf("Error on start error, got %v, %v", res.Status, err)
+	}
+	if _, err := r.Wait(); err != errNotRunning {
+		t.Errorf("Error on wait without start, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package logexec
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is one line of output from a command.
+type Record struct {
+	Time time.Time
+	// Tag identifies the command, like the tag of a syslog message.
+	Tag string
+	// Stream is stdout or stderr.
+	Stream string
+	// Priority is the syslog facility and level of the line.
+	Priority syslog.Priority
+	Line     []byte
+}
+
+// Sink is a destination for records. A Sink is used by one goroutine at a
+// time.
+type Sink interface {
+	// Write sends r to the destination. r.Line is only valid during the
+	// call.
+	Write(r Record) error
+	// Flush sends any records that are buffered.
+	Flush() error
+	// Close flushes and releases the destination.
+	Close() error
+	// Reopen reconnects to the destination or reopens its file, such as
+	// after log rotation or a restart of syslog.
+	Reopen() error
+}
+
+// MultiLineSink is implemented by sinks that send a record whose Line
+// holds several lines as one message, as written by a ChunkWriter, rather
+// than breaking the framing of their destination.
+type MultiLineSink interface {
+	Sink
+	MultiLine()
+}
+
+// SinkFactory opens the Sink for a URL with the scheme it was registered
+// for.
+type SinkFactory func(u *url.URL) (Sink, error)
+
+var (
+	sinksMu sync.Mutex
+	sinks   = map[string]SinkFactory{}
+)
+
+// RegisterSink makes the sinks opened by f available to OpenSink as URLs
+// with scheme. It panics if scheme is already registered, and is meant to
+// be called from an init function.
+func RegisterSink(scheme string, f SinkFactory) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if _, ok := sinks[scheme]; ok {
+		panic("logexec: sink scheme registered twice: " + scheme)
+	}
+	sinks[scheme] = f
+}
+
+// SinkSchemes returns the registered sink schemes in order.
+func SinkSchemes() []string {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	var schemes []string
+	for scheme := range sinks {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// OpenSink opens the sink for rawurl using the factory registered for its
+// scheme.
+func OpenSink(rawurl string) (Sink, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	sinksMu.Lock()
+	f, ok := sinks[u.Scheme]
+	sinksMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("logexec: unknown sink scheme %q in %s", u.Scheme, rawurl)
+	}
+	return f(u)
+}
+
+// SinkWriter returns a writer that sends each Write to s as the Line of a
+// copy of r, stamped with the current time. Used as the sink of a
+// LineWriter, it sends each line of output to s.
+func SinkWriter(s Sink, r Record) io.Writer {
+	return &sinkWriter{sink: s, record: r}
+}
+
+type sinkWriter struct {
+	sink   Sink
+	record Record
+}
+
+func (w *sinkWriter) Write(b []byte) (int, error) {
+	r := w.record
+	r.Time = time.Now()
+	r.Line = b
+	if err := w.sink.Write(r); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,272 @@
+package logexec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// PluginVersion is the version of the plugin protocol spoken by exec sinks.
+const PluginVersion = 1
+
+// maxFrame bounds the frames read from a plugin.
+const maxFrame = 1 << 20
+
+// DefaultPluginTimeout is how long an exec sink waits for its helper to take
+// a frame and answer it, or to exit once closed, unless the URL gives a
+// timeout.
+const DefaultPluginTimeout = 10 * time.Second
+
+var errPluginStopped = errors.New("logexec: plugin is not running")
+
+func init() {
+	RegisterSink("exec", func(u *url.URL) (Sink, error) {
+		return OpenExecSink(u, nil, nil)
+	})
+}
+
+// execSink sends records to a helper program, for destinations that are
+// not compiled in. It is opened from a URL such as
+// exec:///usr/local/bin/shipper?arg=-v&arg=prod&timeout=5s, which runs
+// the program with the arg values as its arguments.
+//
+// The protocol is spoken over the helper's stdin and stdout as frames of a
+// 4-byte big-endian length followed by that many bytes. Each frame from
+// logexec is a JSON object with a "type":
+//
+//	{"type":"hello","version":1}
+//	{"type":"record","time":"2006-01-02T15:04:05.999999999Z","tag":"myjob",
+//	 "stream":"stdout","priority":134,"line":"text"}
+//	{"type":"reopen"}
+//
+// The helper answers every frame with a frame of its own, empty if it has
+// accepted the frame and holding an error message otherwise. A helper that
+// does not answer within the timeout, DefaultPluginTimeout if not given, is
+// killed and started again. Invalid UTF-8 in lines is replaced with U+FFFD.
+// The helper's stderr is that of logexec, and its stdin is closed when the
+// sink is closed.
+type execSink struct {
+	path    string
+	args    []string
+	timeout time.Duration
+	// startCmd and waitCmd start and wait for the helper.
+	startCmd, waitCmd func(cmd *exec.Cmd) error
+
+	// cmd is the running helper, or nil once it has been waited for and
+	// could not be started again.
+	cmd *exec.Cmd
+	// in and out are the pipes to the helper's stdin and stdout, which
+	// take deadlines.
+	in, out *os.File
+	r       *bufio.Reader
+}
+
+// pluginFrame is a frame sent to a plugin.
+type pluginFrame struct {
+	Type     string     `json:"type"`
+	Version  int        `json:"version,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+	Tag      string     `json:"tag,omitempty"`
+	Stream   string     `json:"stream,omitempty"`
+	Priority int        `json:"priority,omitempty"`
+	Line     string     `json:"line,omitempty"`
+}
+
+// OpenExecSink opens the exec sink for u, as OpenSink does, with start and
+// wait to start and wait for the helper in place of cmd.Start and cmd.Wait
+// when they are not nil. They are meant for a program that reaps every
+// child itself, as Runner.StartCommand and Runner.WaitCommand are.
+func OpenExecSink(u *url.URL, start, wait func(cmd *exec.Cmd) error) (Sink, error) {
+	if u.Path == "" {
+		return nil, errors.New("logexec: exec sink needs a program")
+	}
+	if start == nil {
+		start = (*exec.Cmd).Start
+	}
+	if wait == nil {
+		wait = (*exec.Cmd).Wait
+	}
+	s := &execSink{
+		path:     u.Path,
+		args:     u.Query()["arg"],
+		timeout:  DefaultPluginTimeout,
+		startCmd: start,
+		waitCmd:  wait,
+	}
+	if t := u.Query().Get("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("logexec: invalid exec sink timeout %q", t)
+		}
+		s.timeout = d
+	}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// start runs the helper and checks that it speaks the protocol.
+func (s *execSink) start() error {
+	cmd := exec.Command(s.path, s.args...)
+	cmd.Stderr = os.Stderr
+	// The pipes are created here rather than with cmd.StdinPipe and
+	// cmd.StdoutPipe, which do not take deadlines.
+	stdin, in, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	out, stdout, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		in.Close()
+		return err
+	}
+	cmd.Stdin, cmd.Stdout = stdin, stdout
+	err = s.startCmd(cmd)
+	stdin.Close()
+	stdout.Close()
+	if err != nil {
+		in.Close()
+		out.Close()
+		return err
+	}
+	s.cmd, s.in, s.out, s.r = cmd, in, out, bufio.NewReader(out)
+	if err := s.send(pluginFrame{Type: "hello", Version: PluginVersion}); err != nil {
+		s.Close()
+		return fmt.Errorf("logexec: plugin %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// send writes f and waits for its acknowledgment, up to the timeout.
+func (s *execSink) send(f pluginFrame) error {
+	if s.cmd == nil {
+		return errPluginStopped
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(s.timeout)
+	s.in.SetWriteDeadline(deadline)
+	s.out.SetReadDeadline(deadline)
+	if err := writeFrame(s.in, b); err != nil {
+		return err
+	}
+	ack, err := readFrame(s.r)
+	if err != nil {
+		return err
+	}
+	if len(ack) > 0 {
+		return pluginError(ack)
+	}
+	return nil
+}
+
+// pluginError is an error message from a helper.
+type pluginError string
+
+func (e pluginError) Error() string {
+	return string(e)
+}
+
+// restart stops the helper after err, killing it first if it has stopped
+// answering, and starts it again.
+func (s *execSink) restart(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) && s.cmd != nil {
+		s.cmd.Process.Kill()
+	}
+	s.Close()
+	return s.start()
+}
+
+// Write sends r, starting the helper again once if it has stopped
+// answering.
+func (s *execSink) Write(r Record) error {
+	f := pluginFrame{
+		Type:     "record",
+		Time:     &r.Time,
+		Tag:      r.Tag,
+		Stream:   r.Stream,
+		Priority: int(r.Priority),
+		Line:     string(r.Line),
+	}
+	err := s.send(f)
+	if _, rejected := err.(pluginError); err == nil || rejected {
+		return err
+	}
+	if err := s.restart(err); err != nil {
+		return err
+	}
+	return s.send(f)
+}
+
+// MultiLine marks the sink as taking records of several lines, which are
+// framed by their length and JSON encoded.
+func (s *execSink) MultiLine() {}
+
+func (s *execSink) Flush() error {
+	return nil
+}
+
+// Reopen tells the helper to reopen its destination. A helper that has
+// stopped answering is started again instead, which opens it anew.
+func (s *execSink) Reopen() error {
+	err := s.send(pluginFrame{Type: "reopen"})
+	if _, rejected := err.(pluginError); err == nil || rejected {
+		return err
+	}
+	return s.restart(err)
+}
+
+// Close closes the helper's stdin and waits for it to exit, killing it if
+// it has not within the timeout. A helper that has already been waited for
+// is not waited for again.
+func (s *execSink) Close() error {
+	if s.cmd == nil {
+		return nil
+	}
+	s.in.Close()
+	kill := time.AfterFunc(s.timeout, func() {
+		s.cmd.Process.Kill()
+	})
+	err := s.waitCmd(s.cmd)
+	kill.Stop()
+	s.out.Close()
+	s.cmd = nil
+	return err
+}
+
+func writeFrame(w io.Writer, b []byte) error {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(b)))
+	if _, err := w.Write(n[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if size > maxFrame {
+		return nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,181 @@
+package logexec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as an exec sink helper when asked to.
+func TestMain(m *testing.M) {
+	if out := os.Getenv("LOGEXEC_TEST_PLUGIN"); out != "" {
+		os.Exit(runTestPlugin(out))
+	}
+	os.Exit(m.Run())
+}
+
+// runTestPlugin appends the type and line of every frame to out. It rejects
+// lines of "reject", exits without answering the first line of "exit", and
+// stops answering at the first line of "hang". It exits at once, before the
+// hello frame, while out.refuse exists.
+func runTestPlugin(out string) int {
+	if _, err := os.Stat(out + ".refuse"); err == nil {
+		return 1
+	}
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+	in := bufio.NewReader(os.Stdin)
+	for {
+		b, err := readFrame(in)
+		if err != nil {
+			return 0
+		}
+		var frame pluginFrame
+		json.Unmarshal(b, &frame)
+		fmt.Fprintf(f, "%s %d %s %s\n", frame.Type, frame.Version, frame.Stream, frame.Line)
+		switch frame.Line {
+		case "exit":
+			if _, err := os.Stat(out + ".exited"); err != nil {
+				ioutil.WriteFile(out+".exited", nil, 0600)
+				return 0
+			}
+			writeFrame(os.Stdout, nil)
+		case "hang":
+			if _, err := os.Stat(out + ".hung"); err != nil {
+				ioutil.WriteFile(out+".hung", nil, 0600)
+				select {}
+			}
+			writeFrame(os.Stdout, nil)
+		case "reject":
+			writeFrame(os.Stdout, []byte("rejected"))
+		default:
+			writeFrame(os.Stdout, nil)
+		}
+	}
+}
+
+func TestExecSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "frames")
+	os.Setenv("LOGEXEC_TEST_PLUGIN", out)
+	defer os.Unsetenv("LOGEXEC_TEST_PLUGIN")
+
+	s, err := OpenSink("exec://" + os.Args[0] + "?timeout=500ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Record{Time: time.Now(), Tag: "job", Stream: "stdout"}
+	for _, line := range []string{"one", "reject", "exit", "two", "hang"} {
+		r.Line = []byte(line)
+		err := s.Write(r)
+		if want := line == "reject"; (err != nil) != want {
+			t.Errorf("Error on %v, got %v", line, err)
+		}
+	}
+	if err := s.Reopen(); err != nil {
+		t.Errorf("Error on reopen, got %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Error on close, got %v", err)
+	}
+
+	b, _ := ioutil.ReadFile(out)
+	want := []string{
+		"hello 1  ", "record 0 stdout one", "record 0 stdout reject", "record 0 stdout exit",
+		"hello 1  ", "record 0 stdout exit", "record 0 stdout two", "record 0 stdout hang",
+		"hello 1  ", "record 0 stdout hang", "reopen 0  ",
+	}
+	if got := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Error on frames, got %q", got)
+	}
+
+	if _, err := OpenSink("exec:///nonexistent/helper"); err == nil {
+		t.Errorf("Error on missing helper, got no error")
+	}
+	if _, err := OpenSink("exec://" + os.Args[0] + "?timeout=soon"); err == nil {
+		t.Errorf("Error on invalid timeout, got no error")
+	}
+}
+
+func TestOpenExecSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "frames")
+	os.Setenv("LOGEXEC_TEST_PLUGIN", out)
+	defer os.Unsetenv("LOGEXEC_TEST_PLUGIN")
+
+	var started, waited int
+	start := func(cmd *exec.Cmd) error {
+		started++
+		return cmd.Start()
+	}
+	wait := func(cmd *exec.Cmd) error {
+		waited++
+		return cmd.Wait()
+	}
+	u, _ := url.Parse("exec://" + os.Args[0] + "?timeout=5s")
+	s, err := OpenExecSink(u, start, wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The helper exits on the first line and is started again.
+	r := Record{Time: time.Now(), Tag: "job", Stream: "stdout", Line: []byte("exit")}
+	if err := s.Write(r); err != nil {
+		t.Errorf("Error on write, got %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Error on close, got %v", err)
+	}
+	if started != 2 || waited != 2 {
+		t.Errorf("Error on start and wait, got %v, %v", started, waited)
+	}
+}
+
+func TestExecSinkFailedRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "frames")
+	os.Setenv("LOGEXEC_TEST_PLUGIN", out)
+	defer os.Unsetenv("LOGEXEC_TEST_PLUGIN")
+
+	s, err := OpenSink("exec://" + os.Args[0] + "?timeout=500ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The helper exits on the first line, and cannot be started again.
+	if err := ioutil.WriteFile(out+".refuse", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := Record{Time: time.Now(), Tag: "job", Stream: "stdout"}
+	for _, line := range []string{"exit", "one"} {
+		r.Line = []byte(line)
+		if err := s.Write(r); err == nil {
+			t.Errorf("Error on %v, got no error", line)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Error on close, got %v", err)
+	}
+}
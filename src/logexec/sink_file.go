@@ -0,0 +1,238 @@
+package logexec
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultKeep is the number of rotated files a file sink keeps when it
+// rotates by size without a keep parameter.
+const defaultKeep = 5
+
+func init() {
+	RegisterSink("file", openFileSink)
+}
+
+// fileSink appends records to a file as lines of time, tag, stream and
+// text. It is opened from a URL such as file:///var/log/myjob.log.
+//
+// With a max-size parameter, in bytes, the file is rotated once it would
+// grow beyond that size: it is renamed with the suffix .1, older files
+// move up to .2 and so on, and only the newest keep of them are kept, 5
+// by default. With compress=gzip the rotated files are compressed, for
+// example file:///var/log/myjob.log?max-size=10485760&keep=3&compress=gzip.
+// A file is compressed in the background, and one that could not be is
+// kept uncompressed, counting towards keep like the others.
+type fileSink struct {
+	path     string
+	f        *os.File
+	w        *bufio.Writer
+	size     int64
+	maxSize  int64
+	keep     int
+	compress bool
+	// unopened is set while the file has been rotated but the new one
+	// could not be opened, and the old one is still written to.
+	unopened bool
+
+	// compressed is closed once the last rotated file is compressed, and
+	// compressErr is then the error compressing it, if any.
+	compressed  chan struct{}
+	compressErr error
+}
+
+func openFileSink(u *url.URL) (Sink, error) {
+	if u.Path == "" {
+		return nil, errors.New("logexec: file sink needs a path")
+	}
+	s := &fileSink{path: u.Path, keep: defaultKeep}
+	q := u.Query()
+	if v := q.Get("max-size"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("logexec: invalid file sink max-size %q", v)
+		}
+		s.maxSize = n
+	}
+	if v := q.Get("keep"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("logexec: invalid file sink keep %q", v)
+		}
+		s.keep = n
+	}
+	switch q.Get("compress") {
+	case "", "none":
+	case "gzip":
+		s.compress = true
+	default:
+		return nil, fmt.Errorf("logexec: unsupported file sink compression %q, must be gzip or none", q.Get("compress"))
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+	s.w = bufio.NewWriter(f)
+	s.size = fi.Size()
+	return nil
+}
+
+func (s *fileSink) Write(r Record) error {
+	line := fmt.Sprintf("%s %s %s: %s\n", r.Time.Format(time.RFC3339), r.Tag, r.Stream, r.Line)
+	// The line is written even if rotating failed.
+	var rerr error
+	switch {
+	case s.unopened:
+		rerr = s.openRotated()
+	case s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize:
+		rerr = s.rotate()
+	}
+	n, err := s.w.WriteString(line)
+	s.size += int64(n)
+	if err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (s *fileSink) Flush() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.compressError(false)
+}
+
+func (s *fileSink) Close() error {
+	err := s.w.Flush()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := s.compressError(true); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// compressError returns, once, the error compressing the last rotated
+// file. With wait, it waits for the compression to finish.
+func (s *fileSink) compressError(wait bool) error {
+	if s.compressed == nil {
+		return nil
+	}
+	if !wait {
+		select {
+		case <-s.compressed:
+		default:
+			return nil
+		}
+	}
+	<-s.compressed
+	s.compressed = nil
+	err := s.compressErr
+	s.compressErr = nil
+	return err
+}
+
+// Reopen reopens the file by name, so that a file moved away by log
+// rotation is replaced by a new one.
+func (s *fileSink) Reopen() error {
+	if s.unopened {
+		return s.openRotated()
+	}
+	if err := s.Close(); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// rotate moves the file out of the way, dropping the oldest rotated file
+// beyond keep, and starts a new one.
+func (s *fileSink) rotate() error {
+	// A rotated file is only moved once it is compressed.
+	err := s.compressError(true)
+	for _, ext := range []string{"", ".gz"} {
+		os.Remove(s.path + "." + strconv.Itoa(s.keep) + ext)
+		for n := s.keep - 1; n >= 1; n-- {
+			os.Rename(s.path+"."+strconv.Itoa(n)+ext, s.path+"."+strconv.Itoa(n+1)+ext)
+		}
+	}
+	if rerr := os.Rename(s.path, s.path+".1"); rerr != nil {
+		return rerr
+	}
+	if oerr := s.openRotated(); oerr != nil {
+		return oerr
+	}
+	return err
+}
+
+// openRotated starts the new file once the old one has been moved to .1,
+// and compresses the old one. Until the new file can be opened, the old
+// one is written to and the files are not rotated again, so that none of
+// them is dropped.
+func (s *fileSink) openRotated() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	old := s.f
+	if err := s.open(); err != nil {
+		s.unopened = true
+		return err
+	}
+	s.unopened = false
+	old.Close()
+	if s.compress {
+		done := make(chan struct{})
+		s.compressed = done
+		go func() {
+			s.compressErr = gzipFile(s.path + ".1")
+			close(done)
+		}()
+	}
+	return nil
+}
+
+// gzipFile compresses the file at path to path.gz and removes it.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,95 @@
+package logexec
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// syslogPaths are where the local syslog daemon is looked for when a
+// syslog URL has no path.
+var syslogPaths = []string{
+	"/run/systemd/journal/syslog",
+	"/dev/log",
+	"/var/run/syslog",
+	"/var/run/log",
+}
+
+func init() {
+	RegisterSink("syslog", openSyslogSink)
+}
+
+// syslogSink sends records to the local syslog daemon with their own tag
+// and priority. It is opened from syslog: for the usual socket, or a URL
+// such as syslog:///dev/log naming one.
+type syslogSink struct {
+	paths []string
+	conn  net.Conn
+}
+
+func openSyslogSink(u *url.URL) (Sink, error) {
+	if u.Host != "" {
+		return nil, errors.New("logexec: only local syslog sockets are supported")
+	}
+	s := &syslogSink{paths: syslogPaths}
+	if u.Path != "" {
+		s.paths = []string{u.Path}
+	}
+	if err := s.Reopen(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *syslogSink) Reopen() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range s.paths {
+			if conn, err := net.Dial(network, path); err == nil {
+				s.conn = conn
+				return nil
+			}
+		}
+	}
+	return errors.New("logexec: cannot connect to syslog")
+}
+
+// Write sends r in the format of log/syslog for local sockets, connecting
+// again once if the connection has failed.
+func (s *syslogSink) Write(r Record) error {
+	msg := fmt.Sprintf("<%d>%s %s[%d]: %s\n",
+		r.Priority, r.Time.Format(time.Stamp), r.Tag, os.Getpid(), r.Line)
+	if s.conn != nil {
+		if _, err := s.conn.Write([]byte(msg)); err == nil {
+			return nil
+		}
+	}
+	if err := s.Reopen(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+// MultiLine marks the sink as taking records of several lines, which a
+// local syslog socket receives as one message.
+func (s *syslogSink) MultiLine() {}
+
+func (s *syslogSink) Flush() error {
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,209 @@
+package logexec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"log/syslog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestOpenSink(t *testing.T) {
+	if got := SinkSchemes(); !reflect.DeepEqual(got, []string{"exec", "file", "syslog"}) {
+		t.Errorf("Error on schemes, got %v", got)
+	}
+	for _, u := range []string{"nope://x", "file:", "exec:", "syslog://host:514", ":"} {
+		if _, err := OpenSink(u); err == nil {
+			t.Errorf("Error on %v, got no error", u)
+		}
+	}
+}
+
+type nullSink struct{ Sink }
+
+func TestRegisterSink(t *testing.T) {
+	RegisterSink("null-test", func(*url.URL) (Sink, error) { return nullSink{}, nil })
+	if s, err := OpenSink("null-test:"); err != nil || s != (nullSink{}) {
+		t.Errorf("Error on registered sink, got %v, %v", s, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Error on registering twice, got no panic")
+		}
+	}()
+	RegisterSink("null-test", nil)
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	s, err := OpenSink("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewLineWriter(SinkWriter(s, Record{Tag: "job", Stream: "stderr", Priority: syslog.LOG_ERR}), LineOptions{})
+	w.Write([]byte("one\n"))
+	os.Rename(path, path+".1")
+	s.Reopen()
+	w.Write([]byte("two\n"))
+	s.Close()
+
+	for _, f := range []struct{ path, want string }{{path + ".1", "job stderr: one\n"}, {path, "job stderr: two\n"}} {
+		b, err := ioutil.ReadFile(f.path)
+		if err != nil || !strings.HasSuffix(string(b), f.want) {
+			t.Errorf("Error on %v, got %q, %v", f.path, b, err)
+		}
+	}
+}
+
+func TestFileSinkRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	// Each line is 40 bytes, so every file holds two of them.
+	s, err := OpenSink("file://" + path + "?max-size=80&keep=2&compress=gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		s.Write(Record{Time: time.Unix(0, 0).UTC(), Tag: "job", Stream: "stdout", Line: []byte("line " + l)})
+	}
+	s.Close()
+
+	for _, f := range []struct{ path, want string }{
+		{path, "line 7\n"},
+		{path + ".1.gz", "line 5\n1970-01-01T00:00:00Z job stdout: line 6\n"},
+		{path + ".2.gz", "line 3\n1970-01-01T00:00:00Z job stdout: line 4\n"},
+	} {
+		b, err := ioutil.ReadFile(f.path)
+		if err == nil && strings.HasSuffix(f.path, ".gz") {
+			var zr *gzip.Reader
+			if zr, err = gzip.NewReader(bytes.NewReader(b)); err == nil {
+				b, err = ioutil.ReadAll(zr)
+			}
+		}
+		if err != nil || !strings.HasSuffix(string(b), f.want) {
+			t.Errorf("Error on %v, got %q, %v", f.path, b, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3.gz"); !os.IsNotExist(err) {
+		t.Errorf("Error on %v, got %v", path+".3.gz", err)
+	}
+
+	for _, u := range []string{"?max-size=0", "?max-size=x", "?keep=0", "?compress=zstd"} {
+		if _, err := OpenSink("file://" + path + u); err == nil {
+			t.Errorf("Error on %v, got no error", u)
+		}
+	}
+}
+
+func TestFileSinkRotateFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	// A directory in the way of the rotated file makes rotating fail.
+	if err := os.MkdirAll(filepath.Join(path+".1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := OpenSink("file://" + path + "?max-size=40&keep=1&compress=gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range []string{"1", "2", "3"} {
+		err := s.Write(Record{Time: time.Unix(0, 0).UTC(), Tag: "job", Stream: "stdout", Line: []byte("line " + l)})
+		if (err != nil) != (i > 0) {
+			t.Errorf("Error on %v, got %v", l, err)
+		}
+	}
+	if err := s.Reopen(); err != nil {
+		t.Errorf("Error on reopen, got %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Error on close, got %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil || strings.Count(string(b), "\n") != 3 {
+		t.Errorf("Error on %v, got %q, %v", path, b, err)
+	}
+}
+
+func TestFileSinkRotateUnopened(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	s, err := OpenSink("file://" + path + "?max-size=40&keep=1&compress=gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(l string) error {
+		return s.Write(Record{Time: time.Unix(0, 0).UTC(), Tag: "job", Stream: "stdout", Line: []byte("line " + l)})
+	}
+	write("1")
+
+	// Running out of descriptors lets the file be rotated but not opened
+	// again.
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	low := lim
+	low.Cur = uint64(f.Fd())
+	f.Close()
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &low); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"2", "3"} {
+		if err := write(l); err == nil {
+			t.Errorf("Error on %v, got no error", l)
+		}
+	}
+	syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lim)
+	if err := write("4"); err != nil {
+		t.Errorf("Error on 4, got %v", err)
+	}
+	s.Close()
+
+	for _, f := range []struct{ path, want string }{
+		{path, "line 4\n"},
+		{path + ".1.gz", "line 1\n1970-01-01T00:00:00Z job stdout: line 2\n1970-01-01T00:00:00Z job stdout: line 3\n"},
+	} {
+		b, err := ioutil.ReadFile(f.path)
+		if err == nil && strings.HasSuffix(f.path, ".gz") {
+			var zr *gzip.Reader
+			if zr, err = gzip.NewReader(bytes.NewReader(b)); err == nil {
+				b, err = ioutil.ReadAll(zr)
+			}
+		}
+		if err != nil || !strings.HasSuffix(string(b), f.want) {
+			t.Errorf("Error on %v, got %q, %v", f.path, b, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package logexec
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// WaitStatus extracts the wait status from an error returned by
+// exec.Cmd.Wait, or by anything else with a Sys method returning one.
+func WaitStatus(err error) (syscall.WaitStatus, bool) {
+	ose, ok := err.(interface {
+		Sys() interface{}
+	})
+	if !ok {
+		return 0, false
+	}
+	ws, ok := ose.Sys().(syscall.WaitStatus)
+	return ws, ok
+}
+
+// startStatus returns the status a shell would exit with when a command
+// could not be run: 127 if it was not found and 126 if it could not be
+// executed.
+func startStatus(err error) int {
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		return 127
+	case errors.Is(err, os.ErrPermission), errors.Is(err, syscall.ENOEXEC), errors.Is(err, syscall.EISDIR):
+		return 126
+	}
+	// Unknown error type, default to 1
+	return 1
+}
+
+// ExitStatus returns the exit status for err from starting or waiting for
+// a command, using the shell conventions of 128 plus the signal number for
+// commands killed by a signal, and 127 or 126 for commands that could not
+// be found or executed.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	ws, ok := WaitStatus(err)
+	if !ok {
+		return startStatus(err)
+	}
+	if ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ws.ExitStatus()
+}
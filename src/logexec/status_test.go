@@ -0,0 +1,30 @@
+package logexec
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("not an exit"), 1},
+		{&exec.Error{Name: "missing", Err: exec.ErrNotFound}, 127},
+		{&os.PathError{Op: "fork/exec", Path: "./missing", Err: syscall.ENOENT}, 127},
+		{&os.PathError{Op: "fork/exec", Path: "/etc/passwd", Err: syscall.EACCES}, 126},
+		{&os.PathError{Op: "fork/exec", Path: "./script", Err: syscall.ENOEXEC}, 126},
+		{exec.Command("sh", "-c", "exit 3").Run(), 3},
+		{exec.Command("sh", "-c", "kill -9 $$").Run(), 137},
+	}
+	for _, tt := range tests {
+		if got := ExitStatus(tt.err); got != tt.want {
+			t.Errorf("Error on %v, got %v", tt.err, got)
+		}
+	}
+}